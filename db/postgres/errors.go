@@ -33,3 +33,10 @@ func IsNotNullViolation(err error) bool {
 	var pgErr *pgconn.PgError
 	return errors.As(err, &pgErr) && pgErr.Code == "23502"
 }
+
+// IsCheckViolation checks if the provided error is a check violation error in PostgresSQL.
+// It returns true if the error is a check violation error (error code "23514"), and false otherwise.
+func IsCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
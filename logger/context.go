@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type contextKey struct{}
+
+// WithContext returns a copy of the context that carries the logger.
+// The logger is also available to zerolog.Ctx.
+//
+// Parameters:
+// - ctx: The parent context.
+// - l: The logger to carry, usually a child of the global logger with request fields.
+//
+// Returns:
+// - context.Context: The context that carries the logger.
+func WithContext(ctx context.Context, l zerolog.Logger) context.Context {
+	ctx = context.WithValue(ctx, contextKey{}, &l)
+	return l.WithContext(ctx)
+}
+
+// FromContext returns the logger carried by the context, or the global logger when the context has none.
+// Within a request, it returns the logger injected by the ContextLogger middleware of web/chi/middleware.
+//
+// Parameters:
+// - ctx: The context to read the logger from.
+//
+// Returns:
+// - *zerolog.Logger: The logger to write with.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zerolog.Logger); ok {
+		return l
+	}
+	return &log.Logger
+}
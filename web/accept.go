@@ -0,0 +1,144 @@
+package web
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaRange is a media range of an Accept header with its quality value.
+// It contains the following fields:
+// - Type: the lower-cased type, "*" for any type
+// - Subtype: the lower-cased subtype, "*" for any subtype
+// - Params: the media type parameters other than the quality value
+// - Quality: the quality value between 0 and 1, 1 when absent
+type MediaRange struct {
+	Type, Subtype string
+	Params        map[string]string
+	Quality       float64
+}
+
+// Matches reports whether the media type matches the media range.
+// Wildcards match any type or subtype. Parameters of the range must have the same value in the media type when
+// it declares them, and are ignored otherwise, so "application/json; charset=utf-8" accepts "application/json".
+//
+// Parameters:
+// - mediaType: The media type to check, e.g. "application/json; charset=utf-8".
+//
+// Returns:
+// - bool: Whether the media type is acceptable for the media range.
+func (m MediaRange) Matches(mediaType string) bool {
+	base, params, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	typ, subtype, _ := strings.Cut(base, "/")
+
+	if m.Type != "*" && m.Type != typ {
+		return false
+	}
+	if m.Subtype != "*" && m.Subtype != subtype {
+		return false
+	}
+	for key, value := range m.Params {
+		if offered, ok := params[key]; ok && !strings.EqualFold(offered, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m MediaRange) specificity() int {
+	switch {
+	case m.Type == "*":
+		return 0
+	case m.Subtype == "*":
+		return 1
+	default:
+		return 2 + len(m.Params)
+	}
+}
+
+// ParseAccept parses the value of an Accept header into media ranges.
+// Invalid media ranges are ignored. The result is ordered from the most preferred to the least preferred range,
+// by quality value first and then by specificity, so "text/html" comes before "text/*" with the same quality.
+//
+// Parameters:
+// - header: The value of the Accept header.
+//
+// Returns:
+// - []MediaRange: The parsed media ranges.
+func ParseAccept(header string) []MediaRange {
+	var ranges []MediaRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		base, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(base, "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			quality = q
+			delete(params, "q")
+		}
+
+		ranges = append(ranges, MediaRange{Type: typ, Subtype: subtype, Params: params, Quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Quality != ranges[j].Quality {
+			return ranges[i].Quality > ranges[j].Quality
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+	return ranges
+}
+
+// Negotiate selects the offered media type that best matches an Accept header.
+// Every offer gets the quality of the most specific range that matches it, and the offer with the highest quality wins.
+// Ties are broken by the order of the offers. An empty header accepts the first offer.
+//
+// Parameters:
+// - header: The value of the Accept header.
+// - offers: The media types that can be produced, in order of preference.
+//
+// Returns:
+// - string: The selected media type, or an empty string if none of the offers is acceptable.
+func Negotiate(header string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := ParseAccept(header)
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		quality, specificity := 0.0, -1
+		for _, mediaRange := range ranges {
+			if mediaRange.specificity() > specificity && mediaRange.Matches(offer) {
+				quality, specificity = mediaRange.Quality, mediaRange.specificity()
+			}
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
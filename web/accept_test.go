@@ -0,0 +1,21 @@
+package web
+
+import "testing"
+
+func TestNegotiateIgnoresParametersNotDeclaredByOffers(t *testing.T) {
+	tests := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"application/json; charset=utf-8", []string{"application/json", "application/xml"}, "application/json"},
+		{"application/json;charset=UTF-8, */*;q=0.1", []string{"application/xml", "application/json"}, "application/json"},
+		{"text/plain; format=flowed", []string{"text/plain; format=fixed", "text/plain"}, "text/plain"},
+		{"text/plain; format=flowed", []string{"text/plain; format=fixed"}, ""},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header, tt.offers...); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.header, tt.offers, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dynastymasra/go-library/web"
+	"github.com/dynastymasra/go-library/web/json"
+)
+
+// Accept is a middleware function that negotiates the media type of the response with the request's Accept header.
+// It selects the offer with the highest quality value, ties are broken by the order of the offers, and a request
+// without an Accept header gets the first offer. The selected media type is stored in the request context with
+// the web.MediaType key, where the web/json functions use it to choose their encoder.
+// If none of the offers is acceptable, it responds with a JSON message and a status of http.StatusNotAcceptable,
+// regardless of the Accept header.
+//
+// Parameters:
+// - offers: The media types the route can produce, in order of preference.
+//
+// Returns:
+// - A middleware function that negotiates the media type and then calls the next handler.
+func Accept(offers ...string) func(http.Handler) http.Handler {
+	message := fmt.Sprintf("Accept does not allow any of %s", strings.Join(offers, ", "))
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			web.AddVary(w.Header(), "Accept")
+
+			mediaType := web.Negotiate(r.Header.Get("Accept"), offers...)
+			if mediaType == "" {
+				messages := []map[string]any{
+					{
+						"message": message,
+					},
+				}
+				ctx := context.WithValue(r.Context(), web.MediaType, json.ContentTypeJSON)
+				json.FailedResponse(w, r.WithContext(ctx), http.StatusNotAcceptable, messages)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), web.MediaType, mediaType)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"fmt"
+	"mime"
 	"net/http"
 	"strings"
 
@@ -9,8 +11,8 @@ import (
 
 // ContentTypeJSON is a middleware function that checks if the request's content type is application/json.
 // It takes a http.Handler as an argument which represents the next handler to be executed in the middleware chain.
-// If the content type of the request is not application/json, it responds with a JSON message and a status of http.StatusUnsupportedMediaType.
-// If the content type is application/json, it calls the next handler in the middleware chain.
+// If the request has a body and its content type is not application/json, it responds with a JSON message and a status of http.StatusUnsupportedMediaType.
+// Otherwise, it calls the next handler in the middleware chain.
 //
 // Parameters:
 // next: The next http.Handler to be executed in the middleware chain.
@@ -18,27 +20,13 @@ import (
 // Returns:
 // A http.Handler that can be used in the middleware chain.
 func ContentTypeJSON(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		contentType := strings.Trim(r.Header.Get("Content-Type"), " ")
-
-		if !strings.Contains(strings.ToLower(contentType), "application/json") {
-			messages := []map[string]any{
-				{
-					"message": "Content-Type is empty or not application/json",
-				},
-			}
-			json.FailedResponse(w, r, http.StatusUnsupportedMediaType, messages)
-			return
-		}
-		next.ServeHTTP(w, r)
-	}
-	return http.HandlerFunc(fn)
+	return contentType("Content-Type is empty or not application/json", "application/json")(next)
 }
 
 // ContentTypeUTF8 is a middleware function that checks if the request's content type is charset=utf-8.
 // It takes a http.Handler as an argument which represents the next handler to be executed in the middleware chain.
-// If the content type of the request is not charset=utf-8, it responds with a JSON message and a status of http.StatusUnsupportedMediaType.
-// If the content type is charset=utf-8, it calls the next handler in the middleware chain.
+// If the request has a body and its content type is not charset=utf-8, it responds with a JSON message and a status of http.StatusUnsupportedMediaType.
+// Otherwise, it calls the next handler in the middleware chain.
 //
 // Parameters:
 // next: The next http.Handler to be executed in the middleware chain.
@@ -46,19 +34,106 @@ func ContentTypeJSON(next http.Handler) http.Handler {
 // Returns:
 // A http.Handler that can be used in the middleware chain.
 func ContentTypeUTF8(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		contentType := strings.Trim(r.Header.Get("Content-Type"), " ")
+	return contentType("Content-Type is empty or not charset=utf-8", "*/*; charset=utf-8")(next)
+}
+
+// ContentType is a middleware function that checks if the request's content type is one of the allowed media types.
+// Allowed media types may use wildcards, e.g. "text/*", the "+json" structured syntax suffix, e.g. "application/*+json",
+// and parameters that the content type must have with the same value, e.g. "application/json; charset=utf-8".
+// Only requests with a body are checked, so bodiless GET and DELETE requests without a content type pass.
+// If the content type is not allowed, it responds with a JSON message and a status of http.StatusUnsupportedMediaType.
+// It panics if an allowed media type cannot be parsed.
+//
+// Parameters:
+// - allowed: The media types the route accepts.
+//
+// Returns:
+// - A middleware function that checks the content type and then calls the next handler.
+func ContentType(allowed ...string) func(http.Handler) http.Handler {
+	message := fmt.Sprintf("Content-Type is empty or not one of %s", strings.Join(allowed, ", "))
+	return contentType(message, allowed...)
+}
+
+func contentType(message string, allowed ...string) func(http.Handler) http.Handler {
+	types := make([]mediaType, 0, len(allowed))
+	for _, value := range allowed {
+		parsed, err := parseMediaType(value)
+		if err != nil {
+			panic(fmt.Sprintf("middleware: invalid media type %q: %v", value, err))
+		}
+		types = append(types, parsed)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !hasBody(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if requested, err := parseMediaType(r.Header.Get("Content-Type")); err == nil {
+				for _, t := range types {
+					if t.matches(requested) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
 
-		if !strings.Contains(strings.ToLower(contentType), "charset=utf-8") {
 			messages := []map[string]any{
 				{
-					"message": "Content-Type is empty or not charset=utf-8",
+					"message": message,
 				},
 			}
 			json.FailedResponse(w, r, http.StatusUnsupportedMediaType, messages)
-			return
 		}
-		next.ServeHTTP(w, r)
+		return http.HandlerFunc(fn)
+	}
+}
+
+// mediaType is a parsed media type with lower-cased type, subtype and parameter names.
+type mediaType struct {
+	typ, subtype string
+	params       map[string]string
+}
+
+func parseMediaType(value string) (mediaType, error) {
+	base, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return mediaType{}, err
+	}
+	typ, subtype, ok := strings.Cut(base, "/")
+	if !ok || typ == "" || subtype == "" {
+		return mediaType{}, fmt.Errorf("%s is not a type/subtype", base)
 	}
-	return http.HandlerFunc(fn)
+	return mediaType{typ: typ, subtype: subtype, params: params}, nil
+}
+
+// matches reports whether the requested media type is allowed by m.
+func (m mediaType) matches(requested mediaType) bool {
+	if m.typ != "*" && m.typ != requested.typ {
+		return false
+	}
+
+	switch {
+	case m.subtype == "*":
+	case strings.HasPrefix(m.subtype, "*+"):
+		if !strings.HasSuffix(requested.subtype, m.subtype[1:]) {
+			return false
+		}
+	case m.subtype != requested.subtype:
+		return false
+	}
+
+	for key, value := range m.params {
+		if !strings.EqualFold(requested.params[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBody reports whether the request carries a body, requests with an unknown length are assumed to have one.
+func hasBody(r *http.Request) bool {
+	return r.ContentLength != 0 || len(r.TransferEncoding) > 0
 }
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dynastymasra/go-library/logger"
+	"github.com/dynastymasra/go-library/web"
+)
+
+// ContextLogger is a middleware function that injects a request-scoped logger into the request context.
+// The logger is a child of the global logger with the request ID, method, remote IP, the trace and span IDs of
+// TraceContext, and the service name and version set by Service.AddServiceHeader. Every line it writes also has
+// the chi route pattern of the request.
+// Handlers read it with logger.FromContext, and HTTPLogger uses it for the access log when ContextLogger comes first
+// in the middleware chain, so all lines of a request are correlated. It must come after middleware.RequestID,
+// middleware.RealIP, TraceContext and Service.AddServiceHeader to log their values.
+//
+// The function takes the next http.Handler to call in the middleware chain.
+// It returns a new http.Handler that injects the logger and then calls the next handler.
+func ContextLogger(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		fields := log.Logger.With().Str(web.RequestID, middleware.GetReqID(r.Context())).
+			Str("method", r.Method).Str("remote_ip", ip)
+		if tc, ok := web.TraceFromContext(r.Context()); ok {
+			fields = fields.Str(web.TraceID, tc.TraceID).Str(web.SpanID, tc.SpanID)
+		}
+		if name, ok := r.Context().Value(web.ServiceName).(string); ok && name != "" {
+			fields = fields.Str(web.ServiceName, name)
+		}
+		if version, ok := r.Context().Value(web.ServiceVersion).(string); ok && version != "" {
+			fields = fields.Str(web.ServiceVersion, version)
+		}
+
+		hook := &routeHook{}
+		hook.rctx.Store(chi.RouteContext(r.Context()))
+		defer hook.done()
+
+		ctx := logger.WithContext(r.Context(), fields.Logger().Hook(hook))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// routeHook adds the route pattern to log lines. The pattern is only known once the request has been routed,
+// so it is read when a line is written, and kept when the request completes because chi reuses its context.
+type routeHook struct {
+	rctx  atomic.Pointer[chi.Context]
+	route atomic.Pointer[string]
+}
+
+func (h *routeHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	if rctx := h.rctx.Load(); rctx != nil {
+		if route := rctx.RoutePattern(); route != "" {
+			e.Str("route", route)
+		}
+		return
+	}
+	if route := h.route.Load(); route != nil && *route != "" {
+		e.Str("route", *route)
+	}
+}
+
+func (h *routeHook) done() {
+	if rctx := h.rctx.Load(); rctx != nil {
+		route := rctx.RoutePattern()
+		h.route.Store(&route)
+		h.rctx.Store(nil)
+	}
+}
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/dynastymasra/go-library/web"
+	"github.com/dynastymasra/go-library/web/json"
+)
+
+// DefaultCORSMethods are the methods allowed by CORS when AllowedMethods is empty.
+var DefaultCORSMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// DefaultCORSHeaders are the request headers allowed by CORS when AllowedHeaders is empty.
+var DefaultCORSHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Authorization",
+	"Content-Type",
+	"If-Match",
+	"If-None-Match",
+	middleware.RequestIDHeader,
+	web.Traceparent,
+	web.Tracestate,
+}
+
+// CORS is the configuration of a middleware that handles Cross-Origin Resource Sharing.
+// It contains the following fields:
+// - AllowedOrigins: the allowed origins, either exact, e.g. "https://app.example.com", with a wildcard subdomain,
+// e.g. "https://*.example.com", or "*" for any origin
+// - AllowedOriginPatterns: the regular expressions of allowed origins, e.g. `^https://pr-\d+\.example\.com$`
+// - AllowOriginFunc: decides whether an origin that is not allowed by the other fields is allowed, not used when nil
+// - AllowedMethods: the methods of cross-origin requests, DefaultCORSMethods when empty
+// - AllowedHeaders: the request headers of cross-origin requests, DefaultCORSHeaders when empty, "*" allows any header
+// - ExposedHeaders: the response headers readable by browsers in addition to the service name and version,
+// request ID, and trace headers
+// - AllowCredentials: whether cross-origin requests may send cookies and credentials, it cannot be combined with
+// the "*" origin, so every allowed origin must be listed or matched
+// - MaxAge: how long browsers cache preflight responses, not sent when zero
+type CORS struct {
+	AllowedOrigins        []string
+	AllowedOriginPatterns []*regexp.Regexp
+	AllowOriginFunc       func(r *http.Request, origin string) bool
+	AllowedMethods        []string
+	AllowedHeaders        []string
+	ExposedHeaders        []string
+	AllowCredentials      bool
+	MaxAge                time.Duration
+}
+
+// Handler is a middleware function that handles the cross-origin requests of browsers.
+// Preflight requests are answered with a status of http.StatusNoContent without calling the next handler.
+// Cross-origin requests from an origin that is not allowed, and preflight requests for a method or a header
+// that is not allowed, are answered with a JSON message and a status of http.StatusForbidden.
+// Requests without an Origin header and same-origin requests are passed to the next handler as is.
+// It panics when AllowCredentials is combined with the "*" origin, or a wildcard that is not a subdomain such as
+// "https://*", which would let any site read credentialed responses.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that handles CORS and then calls the next handler.
+func (c CORS) Handler(next http.Handler) http.Handler {
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	allowedMethods := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		allowedMethods[strings.ToUpper(method)] = true
+	}
+
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+	anyHeader := false
+	allowedHeaders := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		anyHeader = anyHeader || header == "*"
+		allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+
+	exposed := append([]string{
+		web.XServiceName,
+		web.XServiceVersion,
+		middleware.RequestIDHeader,
+		web.XTraceID,
+		web.XSpanID,
+	}, c.ExposedHeaders...)
+	anyOrigin := false
+	for _, origin := range c.AllowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+	}
+	if c.AllowCredentials {
+		for _, origin := range c.AllowedOrigins {
+			if _, suffix, ok := strings.Cut(origin, "*"); ok && !strings.HasPrefix(suffix, ".") {
+				panic(fmt.Sprintf("middleware: CORS cannot allow credentials for the %q origin", origin))
+			}
+		}
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			web.AddVary(w.Header(), "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+		} else {
+			web.AddVary(w.Header(), "Origin")
+		}
+
+		if origin == "" || (!preflight && sameOrigin(r, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.allowOrigin(r, origin) {
+			rejectCORS(w, r, fmt.Sprintf("Origin %s is not allowed", origin))
+			return
+		}
+
+		setAllowOrigin := func() {
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if !preflight {
+			setAllowOrigin()
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+		if !allowedMethods[method] {
+			rejectCORS(w, r, fmt.Sprintf("Method %s is not allowed", method))
+			return
+		}
+
+		var requested []string
+		for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+			for _, header := range strings.Split(value, ",") {
+				if header = strings.TrimSpace(header); header != "" {
+					if !anyHeader && !allowedHeaders[http.CanonicalHeaderKey(header)] {
+						rejectCORS(w, r, fmt.Sprintf("Header %s is not allowed", header))
+						return
+					}
+					requested = append(requested, header)
+				}
+			}
+		}
+
+		setAllowOrigin()
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(requested) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// allowOrigin reports whether the origin is allowed by the configuration.
+func (c CORS) allowOrigin(r *http.Request, origin string) bool {
+	lower := strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == lower {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok && len(lower) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) &&
+			!strings.ContainsAny(lower[len(prefix):len(lower)-len(suffix)], "/:") {
+			return true
+		}
+	}
+
+	for _, pattern := range c.AllowedOriginPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return c.AllowOriginFunc != nil && c.AllowOriginFunc(r, origin)
+}
+
+// sameOrigin reports whether the origin is the origin of the service, which browsers also send with
+// same-origin requests.
+func sameOrigin(r *http.Request, origin string) bool {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+	}
+	return strings.EqualFold(origin, scheme+"://"+r.Host)
+}
+
+func rejectCORS(w http.ResponseWriter, r *http.Request, message string) {
+	messages := []map[string]any{
+		{
+			"message": message,
+		},
+	}
+	json.FailedResponse(w, r, http.StatusForbidden, messages)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dynastymasra/go-library/web/json"
+)
+
+// Deprecated is a middleware function that marks the routes it wraps as deprecated.
+// It sets the Deprecation, Sunset and Link rel="deprecation" headers and logs a warning with the request ID
+// and the caller whenever a route is called. When d.Gone is set and the sunset has passed, it responds with a
+// failed JSON message and a status of http.StatusGone instead of calling the next handler.
+//
+// Parameters:
+// - d: The Deprecation of the routes.
+//
+// Returns:
+// - A middleware function that applies the deprecation and then calls the next handler.
+func Deprecated(d json.Deprecation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !d.Apply(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/dynastymasra/go-library/web"
+	"github.com/dynastymasra/go-library/web/json"
+)
+
+// DefaultJWTAlgorithms are the signing algorithms allowed by JWTAuth when Algorithms is empty.
+var DefaultJWTAlgorithms = []string{"HS256", "RS256", "ES256", "EdDSA"}
+
+// JWTRequirement is what the claims of a bearer token must have to access a route.
+// It contains the following fields:
+// - Scopes: the scopes the token must all have
+// - Roles: the roles the token must have one of
+type JWTRequirement struct {
+	Scopes []string
+	Roles  []string
+}
+
+// JWTAuth is the configuration of a middleware that authenticates requests with JWT bearer tokens.
+// Tokens must have an "exp" claim.
+// It contains the following fields:
+// - Keys: the keys that verify the signatures, e.g. StaticKeys, the keys of LoadPEMKeys or &JWKS{URL: "..."}
+// - Algorithms: the allowed signing algorithms, DefaultJWTAlgorithms when empty
+// - Issuer: the "iss" claim of the tokens, not checked when empty
+// - Audience: the "aud" claim the tokens must have, not checked when empty
+// - Leeway: the clock skew allowed when checking the "exp", "nbf" and "iat" claims
+// - Optional: whether requests without an Authorization header are passed to the next handler without claims
+// - Realm: the realm of the WWW-Authenticate header, not sent when empty
+// - Routes: the requirements of chi route patterns, see JWTRequirement
+type JWTAuth struct {
+	Keys       KeySource
+	Algorithms []string
+	Issuer     string
+	Audience   string
+	Leeway     time.Duration
+	Optional   bool
+	Realm      string
+	Routes     map[string]JWTRequirement
+}
+
+// Handler is a middleware function that validates the bearer token of the Authorization header and stores its
+// claims in the request context, where ClaimsFromContext reads them.
+// Requests without a valid token are answered with a JSON message, a WWW-Authenticate header and a status of
+// http.StatusUnauthorized, and requests whose token does not meet the requirement of their route with
+// a status of http.StatusForbidden, instead of calling the next handler.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that authenticates the request and then calls the next handler.
+func (a JWTAuth) Handler(next http.Handler) http.Handler {
+	algorithms := a.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultJWTAlgorithms
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(a.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if a.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.Issuer))
+	}
+	if a.Audience != "" {
+		options = append(options, jwt.WithAudience(a.Audience))
+	}
+	parser := jwt.NewParser(options...)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			if a.Optional && r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			unauthorized(w, r, a.Realm, "", "Bearer token is required")
+			return
+		}
+
+		claims := &Claims{}
+		keyFunc := func(t *jwt.Token) (any, error) {
+			if a.Keys == nil {
+				return nil, errors.New("no keys configured")
+			}
+			return a.Keys.Key(r.Context(), t)
+		}
+		if _, err := parser.ParseWithClaims(token, claims, keyFunc); err != nil {
+			unauthorized(w, r, a.Realm, "invalid_token", tokenErrorMessage(err))
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), web.Claims, claims))
+
+		if len(a.Routes) > 0 {
+			if requirement, ok := a.Routes[matchRoute(r)]; ok && !requirement.allows(claims) {
+				forbidden(w, r, a.Realm, requirement, claims)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Handler is a middleware function that only calls the next handler when the claims of the request meet
+// the requirement. It must come after JWTAuth, e.g. r.With(JWTRequirement{Scopes: []string{"orders:write"}}.Handler).
+// Requests without claims are answered with a status of http.StatusUnauthorized, and requests whose claims do not
+// meet the requirement with a status of http.StatusForbidden.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that checks the claims and then calls the next handler.
+func (req JWTRequirement) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, "", "", "Bearer token is required")
+			return
+		}
+		if !req.allows(claims) {
+			forbidden(w, r, "", req, claims)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// RequireScopes returns a middleware function that requires the bearer token to have all the scopes.
+//
+// Parameters:
+// - scopes: The scopes, e.g. "orders:read".
+//
+// Returns:
+// - A middleware function, see JWTRequirement.Handler.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return JWTRequirement{Scopes: scopes}.Handler
+}
+
+// RequireRoles returns a middleware function that requires the bearer token to have one of the roles.
+//
+// Parameters:
+// - roles: The roles, e.g. "admin".
+//
+// Returns:
+// - A middleware function, see JWTRequirement.Handler.
+func RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return JWTRequirement{Roles: roles}.Handler
+}
+
+// allows reports whether the claims meet the requirement.
+func (req JWTRequirement) allows(claims *Claims) bool {
+	return claims.HasScopes(req.Scopes...) && (len(req.Roles) == 0 || claims.HasRole(req.Roles...))
+}
+
+// bearerToken returns the token of the Authorization header of the request.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// tokenErrorMessage returns the message of a token validation error that is safe to send to the client.
+func tokenErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "Token is expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "Token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "Token has no expiration"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "Token has an invalid issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "Token has an invalid audience"
+	default:
+		return "Token is invalid"
+	}
+}
+
+// challenge returns the value of a WWW-Authenticate header, RFC 6750.
+func challenge(realm string, params ...string) string {
+	var parts []string
+	if realm != "" {
+		parts = append(parts, fmt.Sprintf("realm=%q", realm))
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%q", params[i], params[i+1]))
+		}
+	}
+	if len(parts) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(parts, ", ")
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, realm, code, message string) {
+	// Requests without a token are not told why, RFC 6750 section 3.1.
+	if code == "" {
+		w.Header().Set("WWW-Authenticate", challenge(realm))
+	} else {
+		w.Header().Set("WWW-Authenticate", challenge(realm, "error", code, "error_description", message))
+	}
+	messages := []map[string]any{
+		{
+			"message": message,
+		},
+	}
+	json.FailedResponse(w, r, http.StatusUnauthorized, messages)
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request, realm string, req JWTRequirement, claims *Claims) {
+	message := "Token does not have any of the required roles"
+	if !claims.HasScopes(req.Scopes...) {
+		message = "Token does not have the required scopes"
+	}
+	w.Header().Set("WWW-Authenticate", challenge(realm, "error", "insufficient_scope",
+		"error_description", message, "scope", strings.Join(req.Scopes, " ")))
+	messages := []map[string]any{
+		{
+			"message": message,
+		},
+	}
+	json.FailedResponse(w, r, http.StatusForbidden, messages)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// Claims are the claims of a bearer token validated by JWTAuth, stored in the request context.
+// It contains the following fields:
+// - RegisteredClaims: the registered claims, e.g. the subject, issuer and audience
+// - Scope: the space-separated scopes of the "scope" claim
+// - Scp: the scopes of the "scp" claim, used by some providers instead of Scope
+// - Roles: the roles of the "roles" claim
+// - Custom: all the claims of the token by name, for the claims that have no field
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope  string           `json:"scope,omitempty"`
+	Scp    jwt.ClaimStrings `json:"scp,omitempty"`
+	Roles  jwt.ClaimStrings `json:"roles,omitempty"`
+	Custom map[string]any   `json:"-"`
+}
+
+// UnmarshalJSON decodes the claims of a token, keeping all of them in Custom.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type claims Claims
+	if err := json.Unmarshal(data, (*claims)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Custom)
+}
+
+// Scopes returns the scopes of the token, from both the "scope" and the "scp" claims.
+func (c *Claims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+// HasScopes reports whether the token has all the scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	granted := c.Scopes()
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasRole reports whether the token has any of the roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimsFromContext returns the claims of the bearer token of the request.
+//
+// Parameters:
+// - ctx: The context of the request.
+//
+// Returns:
+// - *Claims: The claims stored by JWTAuth.
+// - bool: Whether the request has a valid bearer token.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(web.Claims).(*Claims)
+	return claims, ok
+}
+
+// JWTSubject returns the subject of the bearer token of the request, or an empty string for anonymous requests.
+// It is meant to be used with KeyBySubject, e.g. KeyBySubject(JWTSubject).
+func JWTSubject(r *http.Request) string {
+	if claims, ok := ClaimsFromContext(r.Context()); ok {
+		return claims.Subject
+	}
+	return ""
+}
@@ -0,0 +1,366 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultJWKSRefreshInterval is how long JWKS keeps the keys of the document when RefreshInterval is zero.
+const DefaultJWKSRefreshInterval = time.Hour
+
+// DefaultJWKSMinRefreshInterval is the minimum time between two fetches of the document when MinRefreshInterval is zero.
+const DefaultJWKSMinRefreshInterval = time.Minute
+
+// KeySource returns the keys that verify the signatures of bearer tokens.
+type KeySource interface {
+	// Key returns the key that verifies the signature of the token, chosen by its "kid" header.
+	Key(ctx context.Context, token *jwt.Token) (any, error)
+}
+
+// StaticKeys maps key IDs to keys: []byte secrets for HS256, and *rsa.PublicKey, *ecdsa.PublicKey or
+// ed25519.PublicKey for RS256, ES256 and EdDSA. The key of the empty ID verifies the tokens whose "kid" header
+// is empty or unknown.
+type StaticKeys map[string]any
+
+// Key returns the key of the "kid" header of the token.
+func (k StaticKeys) Key(_ context.Context, token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	if key, ok := k[""]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// ParsePEMKey parses a public key, an RSA public key or a certificate in PEM format.
+//
+// Parameters:
+// - data: The PEM encoded key.
+//
+// Returns:
+// - any: The public key, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+// - error: Any error that occurred while parsing the key.
+func ParsePEMKey(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// LoadPEMKeys reads public keys from PEM files, see ParsePEMKey.
+//
+// Parameters:
+// - files: The paths of the files by key ID, the empty ID for the key of tokens without a "kid" header.
+//
+// Returns:
+// - StaticKeys: The keys by key ID.
+// - error: Any error that occurred while reading or parsing the files.
+func LoadPEMKeys(files map[string]string) (StaticKeys, error) {
+	keys := make(StaticKeys, len(files))
+	for kid, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+// JWKS fetches the keys of a JSON Web Key Set document, e.g. the jwks_uri of an OpenID provider.
+// The keys are cached for RefreshInterval and then fetched again in the background while the cached keys are
+// still used. The document is also fetched again when a token has an unknown "kid" header, so rotated keys are
+// picked up, but never more often than MinRefreshInterval once keys have been fetched. Concurrent requests share
+// a single fetch, which does not depend on the context of the requests. When a fetch fails, the error is logged
+// and the cached keys are kept.
+// Its zero value with a URL is ready to use, and it must not be copied after first use.
+// It contains the following fields:
+// - URL: the URL of the document
+// - Client: the client that fetches the document, a client with a timeout of 10 seconds when nil
+// - RefreshInterval: how long the keys are cached, DefaultJWKSRefreshInterval when zero
+// - MinRefreshInterval: the minimum time between two fetches, DefaultJWKSMinRefreshInterval when zero
+type JWKS struct {
+	URL                string
+	Client             *http.Client
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]jwksKey
+	fetched   time.Time
+	attempted time.Time
+	fetching  chan struct{}
+}
+
+type jwksKey struct {
+	key any
+	alg string
+}
+
+// jwk is a JSON Web Key, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// jwksFetchTimeout bounds a fetch when Client has no timeout of its own.
+const jwksFetchTimeout = 30 * time.Second
+
+// Key returns the key of the "kid" header of the token, fetching the document when needed.
+// It only waits for a fetch when no key matches the token, until the fetch completes or ctx is done.
+func (j *JWKS) Key(ctx context.Context, token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	refresh := j.RefreshInterval
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefreshInterval
+	}
+
+	j.mu.Lock()
+	now := time.Now()
+	var done <-chan struct{}
+	if j.keys == nil || now.Sub(j.fetched) > refresh {
+		done = j.refresh(now)
+	}
+	key, ok := j.lookup(kid)
+	if !ok && done == nil {
+		done = j.refresh(now)
+	}
+	j.mu.Unlock()
+
+	if !ok && done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		j.mu.Lock()
+		key, ok = j.lookup(kid)
+		j.mu.Unlock()
+	}
+
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("unknown key %q", kid)
+	case key.alg != "" && key.alg != token.Method.Alg():
+		return nil, fmt.Errorf("key %q is not for %s", kid, token.Method.Alg())
+	}
+	return key.key, nil
+}
+
+// lookup returns the key of the ID, or the only key of the document for tokens without a "kid" header.
+// It must be called with mu held.
+func (j *JWKS) lookup(kid string) (jwksKey, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// refresh starts a fetch of the document in the background, unless one is in progress, or keys were fetched and
+// the last fetch started less than MinRefreshInterval ago. It returns a channel closed when the fetch completes,
+// or nil when no fetch is in progress. It must be called with mu held.
+func (j *JWKS) refresh(now time.Time) <-chan struct{} {
+	if j.fetching != nil {
+		return j.fetching
+	}
+	interval := j.MinRefreshInterval
+	if interval <= 0 {
+		interval = DefaultJWKSMinRefreshInterval
+	}
+	if j.keys != nil && now.Sub(j.attempted) < interval {
+		return nil
+	}
+	j.attempted = now
+
+	done := make(chan struct{})
+	j.fetching = done
+	go func() {
+		defer close(done)
+
+		ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+		defer cancel()
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("url", j.URL).Msg("Failed to fetch JWKS")
+		}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if err == nil {
+			j.keys, j.fetched = keys, time.Now()
+		}
+		j.fetching = nil
+	}()
+	return done
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]jwksKey, error) {
+	client := j.Client
+	if client == nil {
+		client = jwksClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwksKey, len(document.Keys))
+	for _, k := range document.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Warn().Err(err).Str("url", j.URL).Str("kid", k.Kid).Msg("Skipping JWKS key")
+			continue
+		}
+		keys[k.Kid] = jwksKey{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys in document")
+	}
+	return keys, nil
+}
+
+// publicKey returns the key that verifies signatures.
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decodeBase64URL(k.K)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := decodeBase64URL(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -1,45 +1,325 @@
 package middleware
 
 import (
+	"fmt"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/dynastymasra/go-library/logger"
 	"github.com/dynastymasra/go-library/web"
 )
 
+// Redacted replaces the values of redacted headers and query parameters, and the masked parts of logged values.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers whose values are never logged by HTTPLogger.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+	"X-Csrf-Token",
+	"X-Amz-Security-Token",
+}
+
+// DefaultRedactedQueries are the query parameters whose values are never logged by HTTPLogger.
+var DefaultRedactedQueries = []string{
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"token",
+	"api_key",
+	"apikey",
+	"key",
+	"password",
+	"secret",
+	"client_secret",
+	"signature",
+	"sig",
+	"code",
+}
+
+// DefaultMaskPatterns match the secrets and personal data masked in the logged values by HTTPLogger:
+// bearer and basic credentials, JSON Web Tokens, email addresses and payment card numbers.
+var DefaultMaskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:bearer|basic)\s+[a-z0-9\-._~+/]+=*`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`\b(?:4\d{3}|5[1-5]\d{2}|2[2-7]\d{2}|3[47]\d{2}|6(?:011|5\d{2}))[ -]?\d{4}[ -]?\d{4}[ -]?\d{1,4}\b`),
+}
+
+// HTTPLogger is the configuration of a middleware that logs HTTP requests and responses with zerolog.
+// Its zero value logs with secure defaults: the values of DefaultRedactedHeaders and DefaultRedactedQueries
+// are replaced with Redacted, and DefaultMaskPatterns are masked in all other logged values.
+// It contains the following fields:
+// - RedactHeaders: headers redacted in addition to DefaultRedactedHeaders
+// - RedactQueries: query parameters redacted in addition to DefaultRedactedQueries
+// - AllowHeaders: when not empty, only these request and response headers are logged
+// - AllowQueries: when not empty, only these query parameters are logged
+// - MaskPatterns: the patterns masked in logged values, DefaultMaskPatterns when nil
+// - SkipPaths: request paths that are never logged, e.g. "/healthz"
+// - SkipRoutes: chi route patterns that are never logged, e.g. "/users/{id}"
+// - SampleRate: the fraction of successful requests that are logged, between 0 and 1, every request is logged when zero;
+// failed and slow requests are always logged
+// - Levels: the log level by status class, e.g. {5: zerolog.ErrorLevel}, classes without a level are logged at
+// info below 400 and at warn from 400
+// - SlowThreshold: the duration above which a request is flagged as slow and logged at warn level at least,
+// not used when zero
+// - CaptureRequestBody: whether the request body read by the handler is logged
+// - CaptureResponseBody: whether the response body is logged
+// - MaxBodyBytes: the maximum number of body bytes logged, DefaultMaxLoggedBodyBytes when zero
+// - BodyContentTypes: the media types of the logged bodies, DefaultLoggedBodyTypes when nil
+// - RedactFields: JSON fields redacted in logged bodies in addition to DefaultRedactedFields
+type HTTPLogger struct {
+	RedactHeaders []string
+	RedactQueries []string
+	AllowHeaders  []string
+	AllowQueries  []string
+	MaskPatterns  []*regexp.Regexp
+	SkipPaths     []string
+	SkipRoutes    []string
+	SampleRate    float64
+	Levels        map[int]zerolog.Level
+	SlowThreshold time.Duration
+
+	CaptureRequestBody  bool
+	CaptureResponseBody bool
+	MaxBodyBytes        int
+	BodyContentTypes    []string
+	RedactFields        []string
+}
+
 // LogRequestWithZerolog is a middleware function that logs HTTP requests and responses.
 // It logs the start and end time of the request, the duration, the request details (address, path, method, headers, queries),
 // and the response details (status, bytes written, headers). If the response status is 400 or above, it logs a warning.
-// Otherwise, it logs an info message.
+// Otherwise, it logs an info message. Secrets in headers and queries are redacted, see HTTPLogger.
+// It logs the trace and span IDs of TraceContext, and logs with the request-scoped logger of ContextLogger
+// when it comes after it in the middleware chain.
 //
 // The function takes the next http.Handler to call in the middleware chain.
 // It returns a new http.Handler that wraps the original handler with logging functionality.
 func LogRequestWithZerolog(next http.Handler) http.Handler {
+	return HTTPLogger{}.Handler(next)
+}
+
+// Handler is a middleware function that logs HTTP requests and responses with the configuration of the HTTPLogger.
+// See LogRequestWithZerolog for the logged details.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that wraps the next handler with logging functionality.
+func (l HTTPLogger) Handler(next http.Handler) http.Handler {
+	f := l.filter()
+
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		if f.skipPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		now := time.Now().UTC()
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		var requestBody, responseBody *bodyBuffer
+		if l.CaptureRequestBody && r.Body != nil && f.loggableBody(r.Header) {
+			requestBody = &bodyBuffer{max: f.maxBodyBytes}
+			r.Body = &teeReadCloser{ReadCloser: r.Body, w: requestBody}
+		}
+		if l.CaptureResponseBody {
+			responseBody = &bodyBuffer{max: f.maxBodyBytes}
+			ww.Tee(responseBody)
+		}
+
 		defer func() {
-			span := zerolog.Dict().Time("start", now).Time("end", time.Now().UTC()).
-				Str("duration", time.Since(now).String())
+			duration := time.Since(now)
+			level, ok := l.level(r, ww.Status(), duration, f)
+			if !ok {
+				return
+			}
+
+			span := zerolog.Dict().Time("start", now).Time("end", now.Add(duration)).
+				Str("duration", duration.String())
 			request := zerolog.Dict().Str("address", r.RemoteAddr).Str("path", r.URL.Path).
-				Str("method", r.Method).Interface("headers", r.Header).
-				Interface("queries", r.URL.Query())
+				Str("method", r.Method).Interface("headers", f.headers(r.Header)).
+				Interface("queries", f.queries(r.URL.Query()))
 			response := zerolog.Dict().Int("status", ww.Status()).
-				Int("byte", ww.BytesWritten()).Interface("headers", ww.Header())
-
-			if ww.Status() >= http.StatusBadRequest {
-				log.Warn().Str(web.RequestID, middleware.GetReqID(r.Context())).Dict("span", span).
-					Dict("request", request).Dict("response", response).Msg("HTTP message logging")
-			} else {
-				log.Info().Str(web.RequestID, middleware.GetReqID(r.Context())).Dict("span", span).
-					Dict("request", request).Dict("response", response).Msg("HTTP message logging")
+				Int("byte", ww.BytesWritten()).Interface("headers", f.headers(ww.Header()))
+			if requestBody != nil {
+				f.logBody(request, requestBody)
+			}
+			if responseBody != nil && f.loggableBody(ww.Header()) {
+				f.logBody(response, responseBody)
+			}
+
+			access := logger.FromContext(r.Context())
+			event := access.WithLevel(level)
+			if access == &log.Logger {
+				// The request-scoped logger of ContextLogger already has the request ID and the trace IDs.
+				event = event.Str(web.RequestID, middleware.GetReqID(r.Context()))
+				if tc, ok := web.TraceFromContext(r.Context()); ok {
+					event = event.Str(web.TraceID, tc.TraceID).Str(web.SpanID, tc.SpanID)
+				}
+			}
+			if l.SlowThreshold > 0 && duration > l.SlowThreshold {
+				event = event.Bool("slow", true)
 			}
+			event.Dict("span", span).Dict("request", request).Dict("response", response).Msg("HTTP message logging")
 		}()
 		next.ServeHTTP(ww, r)
 	}
 	return http.HandlerFunc(fn)
 }
+
+// level returns the log level of a finished request, or false when the request must not be logged.
+func (l HTTPLogger) level(r *http.Request, status int, duration time.Duration, f logFilter) (zerolog.Level, bool) {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && f.skipRoutes[rctx.RoutePattern()] {
+		return zerolog.NoLevel, false
+	}
+
+	slow := l.SlowThreshold > 0 && duration > l.SlowThreshold
+	if status < http.StatusBadRequest && !slow && l.SampleRate > 0 && rand.Float64() >= l.SampleRate {
+		return zerolog.NoLevel, false
+	}
+
+	level, ok := l.Levels[status/100]
+	if !ok {
+		level = zerolog.InfoLevel
+		if status >= http.StatusBadRequest {
+			level = zerolog.WarnLevel
+		}
+	}
+	if slow && level < zerolog.WarnLevel {
+		level = zerolog.WarnLevel
+	}
+	return level, true
+}
+
+// logFilter is the compiled redaction configuration of an HTTPLogger.
+type logFilter struct {
+	redactHeaders, redactQueries map[string]bool
+	allowHeaders, allowQueries   map[string]bool
+	skipPaths, skipRoutes        map[string]bool
+	patterns                     []*regexp.Regexp
+	redactFields                 map[string]bool
+	bodyTypes                    []mediaType
+	maxBodyBytes                 int
+}
+
+func (l HTTPLogger) filter() logFilter {
+	f := logFilter{
+		redactHeaders: headerSet(append(append([]string(nil), DefaultRedactedHeaders...), l.RedactHeaders...)),
+		redactQueries: lowerSet(append(append([]string(nil), DefaultRedactedQueries...), l.RedactQueries...)),
+		allowHeaders:  headerSet(l.AllowHeaders),
+		allowQueries:  lowerSet(l.AllowQueries),
+		skipPaths:     stringSet(l.SkipPaths),
+		skipRoutes:    stringSet(l.SkipRoutes),
+		patterns:      l.MaskPatterns,
+	}
+	if f.patterns == nil {
+		f.patterns = DefaultMaskPatterns
+	}
+
+	if l.CaptureRequestBody || l.CaptureResponseBody {
+		f.redactFields = lowerSet(append(append([]string(nil), DefaultRedactedFields...), l.RedactFields...))
+		f.maxBodyBytes = l.MaxBodyBytes
+		if f.maxBodyBytes <= 0 {
+			f.maxBodyBytes = DefaultMaxLoggedBodyBytes
+		}
+
+		bodyTypes := l.BodyContentTypes
+		if bodyTypes == nil {
+			bodyTypes = DefaultLoggedBodyTypes
+		}
+		for _, value := range bodyTypes {
+			parsed, err := parseMediaType(value)
+			if err != nil {
+				panic(fmt.Sprintf("middleware: invalid media type %q: %v", value, err))
+			}
+			f.bodyTypes = append(f.bodyTypes, parsed)
+		}
+	}
+	return f
+}
+
+// headers returns the headers to log, without the headers that are not allowed and with redacted values.
+func (f logFilter) headers(header http.Header) map[string][]string {
+	logged := make(map[string][]string, len(header))
+	for key, values := range header {
+		canonical := http.CanonicalHeaderKey(key)
+		if len(f.allowHeaders) > 0 && !f.allowHeaders[canonical] {
+			continue
+		}
+		logged[key] = f.values(values, f.redactHeaders[canonical])
+	}
+	return logged
+}
+
+// queries returns the query parameters to log, without the parameters that are not allowed and with redacted values.
+func (f logFilter) queries(query url.Values) map[string][]string {
+	logged := make(map[string][]string, len(query))
+	for key, values := range query {
+		lower := strings.ToLower(key)
+		if len(f.allowQueries) > 0 && !f.allowQueries[lower] {
+			continue
+		}
+		logged[key] = f.values(values, f.redactQueries[lower])
+	}
+	return logged
+}
+
+func (f logFilter) values(values []string, redact bool) []string {
+	masked := make([]string, len(values))
+	for i, value := range values {
+		if redact {
+			masked[i] = Redacted
+		} else {
+			masked[i] = f.mask(value)
+		}
+	}
+	return masked
+}
+
+// mask replaces the parts of the value that match the mask patterns.
+func (f logFilter) mask(value string) string {
+	for _, pattern := range f.patterns {
+		value = pattern.ReplaceAllString(value, Redacted)
+	}
+	return value
+}
+
+func headerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(name)] = true
+	}
+	return set
+}
+
+func lowerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultMaxLoggedBodyBytes is the maximum number of body bytes logged by HTTPLogger.
+const DefaultMaxLoggedBodyBytes = 4 << 10
+
+// DefaultLoggedBodyTypes are the media types of the bodies logged by HTTPLogger.
+var DefaultLoggedBodyTypes = []string{
+	"application/json",
+	"application/*+json",
+	"text/*",
+}
+
+// DefaultRedactedFields are the JSON fields whose values are never logged by HTTPLogger, compared case-insensitively.
+var DefaultRedactedFields = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"api_key",
+	"apikey",
+	"authorization",
+	"client_secret",
+	"card_number",
+	"cvv",
+	"pin",
+	"ssn",
+}
+
+// bodyBuffer keeps the first bytes written to it and discards the rest, so it never fails the writer it tees.
+type bodyBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *bodyBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(remaining, 0)])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// teeReadCloser copies what the handler reads from the request body, without reading anything on its own.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.w.Write(p[:n]) //nolint:errcheck
+	}
+	return n, err
+}
+
+// loggableBody reports whether a body with the headers is logged, compressed bodies are never logged.
+func (f logFilter) loggableBody(header http.Header) bool {
+	if encoding := header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+
+	requested, err := parseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range f.bodyTypes {
+		if t.matches(requested) {
+			return true
+		}
+	}
+	return false
+}
+
+// logBody adds the captured body to a log dictionary. Complete JSON bodies are logged as JSON with redacted fields,
+// other bodies are logged as masked strings.
+func (f logFilter) logBody(dict *zerolog.Event, body *bodyBuffer) {
+	if body.buf.Len() == 0 {
+		return
+	}
+
+	if !body.truncated {
+		dec := json.NewDecoder(bytes.NewReader(body.buf.Bytes()))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err == nil && !dec.More() {
+			dict.Interface("body", f.redactJSON(doc))
+			return
+		}
+	}
+
+	dict.Str("body", f.mask(body.buf.String()))
+	if body.truncated {
+		dict.Bool("body_truncated", true)
+	}
+}
+
+// redactJSON replaces the values of redacted fields and masks the strings of a decoded JSON document.
+func (f logFilter) redactJSON(doc any) any {
+	switch value := doc.(type) {
+	case map[string]any:
+		for key, member := range value {
+			if f.redactFields[strings.ToLower(key)] {
+				value[key] = Redacted
+			} else {
+				value[key] = f.redactJSON(member)
+			}
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = f.redactJSON(item)
+		}
+		return value
+	case string:
+		return f.mask(value)
+	default:
+		return doc
+	}
+}
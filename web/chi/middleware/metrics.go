@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// UnmatchedRoute is the route label of requests that do not match any route, so unknown paths do not create series.
+const UnmatchedRoute = "unmatched"
+
+// MetricsConfig is the configuration of the RED metrics middleware.
+// It contains the following fields:
+// - Namespace: the prefix of the metric names, e.g. "api" for "api_http_requests_total", no prefix when empty
+// - Service: the service label of requests without the service name set by Service.AddServiceHeader
+// - DurationBuckets: the buckets of the latency histogram in seconds, prometheus.DefBuckets when nil
+// - SizeBuckets: the buckets of the response size histogram in bytes, 100B to 10MB when nil
+// - Registry: the registry of the metrics, a new registry with the Go and process collectors when nil
+type MetricsConfig struct {
+	Namespace, Service           string
+	DurationBuckets, SizeBuckets []float64
+	Registry                     *prometheus.Registry
+}
+
+// Metrics records the rate, errors and duration of HTTP requests in Prometheus metrics.
+// Requests are labeled by method, chi route pattern, status class and service name:
+// - http_requests_total counts the requests
+// - http_request_errors_total counts the requests answered with a 5xx status
+// - http_request_duration_seconds is the latency histogram
+// - http_response_size_bytes is the response size histogram
+// - http_requests_in_flight is the number of requests being served, labeled by method and service
+type Metrics struct {
+	service  string
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewMetrics creates the metrics and registers them in the registry of the configuration.
+//
+// Parameters:
+// - config: The MetricsConfig of the metrics.
+//
+// Returns:
+// - *Metrics: The metrics, with the middleware and the exposition handler.
+// - error: An error if the metrics cannot be registered, e.g. because the registry already has them.
+func NewMetrics(config MetricsConfig) (*Metrics, error) {
+	registry := config.Registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	durationBuckets := config.DurationBuckets
+	if durationBuckets == nil {
+		durationBuckets = prometheus.DefBuckets
+	}
+	sizeBuckets := config.SizeBuckets
+	if sizeBuckets == nil {
+		sizeBuckets = prometheus.ExponentialBuckets(100, 10, 6)
+	}
+
+	labels := []string{"method", "route", "status_class", "service"}
+	m := &Metrics{
+		service:  config.Service,
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "http_request_errors_total",
+			Help:      "Total number of HTTP requests answered with a server error.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests in seconds.",
+			Buckets:   durationBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies in bytes.",
+			Buckets:   sizeBuckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being served.",
+		}, []string{"method", "service"}),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.errors, m.duration, m.size, m.inFlight} {
+		if err := registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler is a middleware function that records the metrics of HTTP requests.
+// It must be used with the router, e.g. router.Use(metrics.Handler), so the route pattern is known when the
+// request completes, and after Service.AddServiceHeader to label requests with the service name.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that wraps the next handler with metrics.
+func (m *Metrics) Handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		service := m.service
+		if name, ok := r.Context().Value(web.ServiceName).(string); ok && name != "" {
+			service = name
+		}
+
+		inFlight := m.inFlight.WithLabelValues(r.Method, service)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		now := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			route := UnmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			labels := prometheus.Labels{
+				"method":       r.Method,
+				"route":        route,
+				"status_class": strconv.Itoa(status/100) + "xx",
+				"service":      service,
+			}
+
+			m.requests.With(labels).Inc()
+			if status >= http.StatusInternalServerError {
+				m.errors.With(labels).Inc()
+			}
+			m.duration.With(labels).Observe(time.Since(now).Seconds())
+			m.size.With(labels).Observe(float64(ww.BytesWritten()))
+		}()
+		next.ServeHTTP(ww, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Exposition returns the handler of the metrics endpoint, which writes the metrics of the registry
+// in the Prometheus text format, e.g. router.Handle("/metrics", metrics.Exposition()).
+//
+// Returns:
+// - An http.Handler that serves the metrics.
+func (m *Metrics) Exposition() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,317 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dynastymasra/go-library/web"
+	"github.com/dynastymasra/go-library/web/json"
+)
+
+// Algorithm is the algorithm of a rate limit.
+type Algorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit.Requests requests, and refills the bucket at Limit.Requests per Limit.Window.
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows Limit.Requests requests in any Limit.Window, estimated from the counts of the current and
+	// the previous fixed windows.
+	SlidingWindow
+)
+
+// Limit is a rate limit.
+// It contains the following fields:
+// - Algorithm: the algorithm of the limit
+// - Requests: the number of requests allowed per Window, requests are not limited when zero
+// - Window: the period of the limit
+type Limit struct {
+	Algorithm Algorithm
+	Requests  int
+	Window    time.Duration
+}
+
+// RateLimitResult is the outcome of taking a request from a limit.
+// It contains the following fields:
+// - Allowed: whether the request is allowed
+// - Remaining: the number of requests that are still allowed now
+// - Reset: the time until the limit is fully available again
+// - RetryAfter: the time until the next request is allowed, zero when the request is allowed
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitState is the state of a limit for a key, kept by a RateLimitStore.
+// Its fields are interpreted by the algorithm of the limit:
+// - TokenBucket: Value is the number of tokens, and At is when they were counted
+// - SlidingWindow: Value and Previous are the counts of the current and the previous window, and At is the start of the current window
+// The zero value is the state of a key that has not made any request.
+type RateLimitState struct {
+	Value    float64
+	Previous float64
+	At       time.Time
+}
+
+// Take takes a request from the limit and updates the state.
+//
+// Parameters:
+// - state: The state of the key, updated in place.
+// - now: The time of the request.
+//
+// Returns:
+// - RateLimitResult: Whether the request is allowed, and the values of the rate limit headers.
+func (l Limit) Take(state *RateLimitState, now time.Time) RateLimitResult {
+	capacity := float64(l.Requests)
+	window := float64(l.Window)
+
+	if l.Algorithm == SlidingWindow {
+		start := now.Truncate(l.Window)
+		if !state.At.Equal(start) {
+			if state.At.Equal(start.Add(-l.Window)) {
+				state.Previous = state.Value
+			} else {
+				state.Previous = 0
+			}
+			state.Value, state.At = 0, start
+		}
+
+		elapsed := float64(now.Sub(start)) / window
+		count := state.Previous*(1-elapsed) + state.Value
+		reset := start.Add(l.Window).Sub(now)
+
+		if count+1 <= capacity {
+			state.Value++
+			return RateLimitResult{Allowed: true, Remaining: int(capacity - math.Ceil(count+1)), Reset: reset}
+		}
+
+		// Find when the weighted count drops low enough to allow one more request.
+		var retryAt time.Time
+		if state.Value <= capacity-1 && state.Previous > 0 {
+			retryAt = start.Add(time.Duration((1 - (capacity-1-state.Value)/state.Previous) * window))
+		} else {
+			retryAt = start.Add(l.Window).Add(time.Duration((1 - (capacity-1)/state.Value) * window))
+		}
+		return RateLimitResult{Remaining: 0, Reset: reset, RetryAfter: max(retryAt.Sub(now), 0)}
+	}
+
+	rate := capacity / window
+	tokens := capacity
+	if !state.At.IsZero() {
+		tokens = min(capacity, state.Value+float64(now.Sub(state.At))*rate)
+	}
+	state.At = now
+
+	if tokens >= 1 {
+		state.Value = tokens - 1
+		return RateLimitResult{
+			Allowed:   true,
+			Remaining: int(state.Value),
+			Reset:     time.Duration((capacity - state.Value) / rate),
+		}
+	}
+	state.Value = tokens
+	return RateLimitResult{
+		Reset:      time.Duration((capacity - tokens) / rate),
+		RetryAfter: time.Duration((1 - tokens) / rate),
+	}
+}
+
+// RateLimitStore keeps the state of the limits of every key.
+type RateLimitStore interface {
+	// Take takes a request from the limit of the key, see Limit.Take.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (RateLimitResult, error)
+}
+
+// KeyFunc returns the key of the client of a request, requests with the same key share their limits.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP returns the IP address of the client, use middleware.RealIP before the rate limiter behind proxies.
+func KeyByIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// KeyByHeader returns a KeyFunc that uses the value of a request header, or the IP address when the header is empty.
+//
+// Parameters:
+// - name: The name of the header, e.g. "X-Tenant-Id".
+//
+// Returns:
+// - KeyFunc: The key function.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			return "header:" + name + ":" + value
+		}
+		return KeyByIP(r)
+	}
+}
+
+// KeyByAPIKey returns a KeyFunc that uses the API key of a request header, or the IP address when the header is empty.
+// The API key is hashed, so it is never kept by the store.
+//
+// Parameters:
+// - name: The name of the header, e.g. "X-Api-Key".
+//
+// Returns:
+// - KeyFunc: The key function.
+func KeyByAPIKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			sum := sha256.Sum256([]byte(value))
+			return "apikey:" + hex.EncodeToString(sum[:])
+		}
+		return KeyByIP(r)
+	}
+}
+
+// KeyBySubject returns a KeyFunc that uses the authenticated subject of a request, or the IP address for anonymous requests.
+// The rate limiter must come after the authentication middleware.
+//
+// Parameters:
+// - subject: Returns the subject of the request, or an empty string when the request is not authenticated.
+//
+// Returns:
+// - KeyFunc: The key function.
+func KeyBySubject(subject func(r *http.Request) string) KeyFunc {
+	return func(r *http.Request) string {
+		if value := subject(r); value != "" {
+			return "sub:" + value
+		}
+		return KeyByIP(r)
+	}
+}
+
+// RateLimiter is the configuration of a rate limiting middleware.
+// It contains the following fields:
+// - Limit: the limit of every route that has no limit of its own
+// - Routes: the limits of chi route patterns, counted separately from the other routes
+// - Key: the key of the client of a request, KeyByIP when nil
+// - Store: the store of the limits, a new MemoryRateLimitStore when nil
+// - FailClosed: whether requests are rejected when the store fails, they are allowed and the error is logged otherwise
+type RateLimiter struct {
+	Limit      Limit
+	Routes     map[string]Limit
+	Key        KeyFunc
+	Store      RateLimitStore
+	FailClosed bool
+}
+
+// Handler is a middleware function that limits the rate of requests of every client.
+// It sets the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers on limited routes.
+// When the limit is exceeded, it responds with a JSON message, a Retry-After header and a status of
+// http.StatusTooManyRequests instead of calling the next handler.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that limits the requests and then calls the next handler.
+func (rl RateLimiter) Handler(next http.Handler) http.Handler {
+	store := rl.Store
+	if store == nil {
+		store = &MemoryRateLimitStore{}
+	}
+	key := rl.Key
+	if key == nil {
+		key = KeyByIP
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		limit, scope := rl.Limit, ""
+		if len(rl.Routes) > 0 {
+			route := matchRoute(r)
+			if routeLimit, ok := rl.Routes[route]; ok {
+				limit, scope = routeLimit, route
+			}
+		}
+		if limit.Requests <= 0 || limit.Window <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := store.Take(r.Context(), scope+"|"+key(r), limit, time.Now())
+		if err != nil {
+			log.Error().Err(err).Str(web.RequestID, middleware.GetReqID(r.Context())).Msg("Failed to take rate limit")
+			if rl.FailClosed {
+				json.HandleError(w, r, json.Internal(err))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Window)))
+
+		if !result.Allowed {
+			json.HandleError(w, r, json.RateLimited("rate limit exceeded", max(result.RetryAfter, time.Second)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// seconds rounds a duration up to whole seconds.
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// MemoryRateLimitStore keeps the limits in memory, so every instance of the service limits requests on its own.
+// Its zero value is ready to use, and keys that have not been used for the window of their limit are removed.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryRateLimitEntry
+	swept   time.Time
+}
+
+type memoryRateLimitEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+// Take takes a request from the limit of the key, see Limit.Take.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil {
+		s.entries = map[string]*memoryRateLimitEntry{}
+	}
+	if now.Sub(s.swept) > time.Minute {
+		for k, entry := range s.entries {
+			if now.After(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.swept = now
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryRateLimitEntry{}
+		s.entries[key] = entry
+	}
+	result := limit.Take(&entry.state, now)
+	entry.expires = now.Add(2 * limit.Window)
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+
+	"github.com/dynastymasra/go-library/db/postgres"
+)
+
+// DefaultRateLimitTable is the table of PostgresRateLimitStore when Table is empty.
+const DefaultRateLimitTable = "rate_limits"
+
+// PostgresRateLimitStore keeps the limits in a Postgres table, so all instances of the service share them.
+// The state of a key is locked while a request is taken from it, which keeps the limits exact under concurrency.
+// It contains the following fields:
+// - DB: the connection to the database, the connection of the db/postgres package when nil, the methods return an
+// error when neither is connected
+// - Table: the name of the table, optionally schema-qualified, e.g. "app.rate_limits", DefaultRateLimitTable when empty, see CreateTable
+type PostgresRateLimitStore struct {
+	DB    *gorm.DB
+	Table string
+}
+
+type rateLimitRow struct {
+	Value    float64
+	Previous float64
+	StateAt  time.Time
+}
+
+// errNoRateLimitDB is returned when DB is nil and the db/postgres package is not connected.
+var errNoRateLimitDB = errors.New("rate limit store has no database connection")
+
+func (s PostgresRateLimitStore) db(ctx context.Context) (*gorm.DB, error) {
+	db := s.DB
+	if db == nil {
+		db = postgres.Config{}.DB()
+	}
+	if db == nil {
+		return nil, errNoRateLimitDB
+	}
+	return db.WithContext(ctx), nil
+}
+
+// table returns the quoted name of the table, a schema-qualified name such as "app.rate_limits" is quoted per part.
+func (s PostgresRateLimitStore) table() string {
+	table := s.Table
+	if table == "" {
+		table = DefaultRateLimitTable
+	}
+	return pgx.Identifier(strings.Split(table, ".")).Sanitize()
+}
+
+// CreateTable creates the table of the store when it does not exist.
+//
+// Parameters:
+// - ctx: The context of the query.
+//
+// Returns:
+// - error: Any error that occurred while creating the table.
+func (s PostgresRateLimitStore) CreateTable(ctx context.Context) error {
+	db, err := s.db(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		key text PRIMARY KEY,
+		value double precision NOT NULL,
+		previous double precision NOT NULL,
+		state_at timestamptz NOT NULL,
+		expires_at timestamptz NOT NULL
+	)`, s.table())).Error
+}
+
+// DeleteExpired removes the keys that have not been used for the window of their limit.
+// It is meant to be called periodically.
+//
+// Parameters:
+// - ctx: The context of the query.
+//
+// Returns:
+// - error: Any error that occurred while deleting the keys.
+func (s PostgresRateLimitStore) DeleteExpired(ctx context.Context) error {
+	db, err := s.db(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE expires_at < ?`, s.table()), time.Now()).Error
+}
+
+// Take takes a request from the limit of the key, see Limit.Take.
+func (s PostgresRateLimitStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (RateLimitResult, error) {
+	db, err := s.db(ctx)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var result RateLimitResult
+	err = db.Transaction(func(tx *gorm.DB) error {
+		insert := fmt.Sprintf(`INSERT INTO %s (key, value, previous, state_at, expires_at)
+			VALUES (?, 0, 0, 'epoch', ?) ON CONFLICT (key) DO NOTHING`, s.table())
+		if err := tx.Exec(insert, key, now).Error; err != nil {
+			return err
+		}
+
+		var row rateLimitRow
+		query := fmt.Sprintf(`SELECT value, previous, state_at FROM %s WHERE key = ? FOR UPDATE`, s.table())
+		if err := tx.Raw(query, key).Scan(&row).Error; err != nil {
+			return err
+		}
+
+		state := RateLimitState{Value: row.Value, Previous: row.Previous, At: row.StateAt}
+		if state.At.Equal(time.Unix(0, 0)) {
+			state = RateLimitState{}
+		}
+		result = limit.Take(&state, now)
+
+		update := fmt.Sprintf(`UPDATE %s SET value = ?, previous = ?, state_at = ?, expires_at = ? WHERE key = ?`, s.table())
+		return tx.Exec(update, state.Value, state.Previous, state.At, now.Add(2*limit.Window), key).Error
+	})
+	return result, err
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// matchRoute returns the chi route pattern that matches the request, or an empty string when no route matches.
+// Middleware used with the router runs before chi routes the request, so the pattern is found by matching
+// the request against the routes of the router.
+func matchRoute(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+	return tctx.RoutePattern()
+}
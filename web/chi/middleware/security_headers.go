@@ -1,6 +1,199 @@
 package middleware
 
-import "net/http"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// NonceSource is the CSP source replaced with the nonce of the request, e.g. CSP{}.With("script-src", "'self'", NonceSource).
+const NonceSource = "'nonce'"
+
+// CSP is a Content-Security-Policy builder. Its directives are written in the order they are added.
+// It contains the following fields:
+// - ReportOnly: whether the policy is sent as Content-Security-Policy-Report-Only, so violations are reported but not blocked
+// - ReportURI: the URL violations are reported to with the report-uri directive, not sent when empty
+// - ReportTo: the Reporting-Endpoints group violations are reported to with the report-to directive, not sent when empty
+type CSP struct {
+	ReportOnly bool
+	ReportURI  string
+	ReportTo   string
+	directives []cspDirective
+}
+
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// With returns a copy of the policy with a directive, replacing the sources of the directive when it already exists.
+//
+// Parameters:
+// - name: The name of the directive, e.g. "script-src".
+// - sources: The sources of the directive, e.g. "'self'" or NonceSource, none for directives such as "upgrade-insecure-requests".
+//
+// Returns:
+// - CSP: The policy with the directive.
+func (c CSP) With(name string, sources ...string) CSP {
+	directives := make([]cspDirective, 0, len(c.directives)+1)
+	replaced := false
+	for _, directive := range c.directives {
+		if directive.name == name {
+			directive.sources, replaced = sources, true
+		}
+		directives = append(directives, directive)
+	}
+	if !replaced {
+		directives = append(directives, cspDirective{name: name, sources: sources})
+	}
+
+	c.directives = directives
+	return c
+}
+
+// usesNonce reports whether a directive of the policy has NonceSource.
+func (c CSP) usesNonce() bool {
+	for _, directive := range c.directives {
+		for _, source := range directive.sources {
+			if source == NonceSource {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns the value of the policy header with the nonce of the request.
+//
+// Parameters:
+// - nonce: The nonce that replaces NonceSource.
+//
+// Returns:
+// - string: The policy, e.g. "default-src 'self'; script-src 'self' 'nonce-abc'".
+func (c CSP) String(nonce string) string {
+	parts := make([]string, 0, len(c.directives)+2)
+	for _, directive := range c.directives {
+		sources := make([]string, 0, len(directive.sources)+1)
+		sources = append(sources, directive.name)
+		for _, source := range directive.sources {
+			if source == NonceSource {
+				source = fmt.Sprintf("'nonce-%s'", nonce)
+			}
+			sources = append(sources, source)
+		}
+		parts = append(parts, strings.Join(sources, " "))
+	}
+	if c.ReportURI != "" {
+		parts = append(parts, "report-uri "+c.ReportURI)
+	}
+	if c.ReportTo != "" {
+		parts = append(parts, "report-to "+c.ReportTo)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// HSTS is the Strict-Transport-Security policy.
+// It contains the following fields:
+// - MaxAge: how long browsers only use HTTPS, the header is not sent when zero
+// - IncludeSubDomains: whether the policy applies to the subdomains
+// - Preload: whether the domain may be included in the browsers' preload lists
+type HSTS struct {
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+func (h HSTS) String() string {
+	value := "max-age=" + strconv.FormatInt(int64(h.MaxAge/time.Second), 10)
+	if h.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// PermissionsPolicy maps the browser features to their allowlists, e.g. {"camera": {}, "geolocation": {"self"}}.
+// An empty allowlist disables the feature, and origins are quoted when the header is written.
+type PermissionsPolicy map[string][]string
+
+func (p PermissionsPolicy) String() string {
+	features := make([]string, 0, len(p))
+	for feature := range p {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	parts := make([]string, 0, len(features))
+	for _, feature := range features {
+		allowlist := make([]string, 0, len(p[feature]))
+		for _, origin := range p[feature] {
+			if origin != "*" && origin != "self" && origin != "src" {
+				origin = strconv.Quote(origin)
+			}
+			allowlist = append(allowlist, origin)
+		}
+		parts = append(parts, fmt.Sprintf("%s=(%s)", feature, strings.Join(allowlist, " ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SecurityHeaders is the configuration of a middleware that adds security headers to HTTP responses.
+// Headers with a zero value are not sent.
+// It contains the following fields:
+// - CSP: the Content-Security-Policy, not sent when nil
+// - HSTS: the Strict-Transport-Security policy
+// - ReferrerPolicy: the Referrer-Policy, e.g. "strict-origin-when-cross-origin"
+// - PermissionsPolicy: the Permissions-Policy
+// - CrossOriginOpenerPolicy: the Cross-Origin-Opener-Policy, e.g. "same-origin"
+// - CrossOriginEmbedderPolicy: the Cross-Origin-Embedder-Policy, e.g. "require-corp"
+// - CrossOriginResourcePolicy: the Cross-Origin-Resource-Policy, e.g. "same-site"
+// - ReportingEndpoints: the Reporting-Endpoints by group name, used by CSP.ReportTo
+// - NoSniff: whether X-Content-Type-Options is set to nosniff
+// - FrameOptions: the X-Frame-Options, e.g. "deny"
+// - XSSProtection: the deprecated X-XSS-Protection
+// - CacheControl: the Cache-Control, e.g. "no-store"
+// - Routes: the headers of chi route patterns, used instead of this configuration for the requests that match them
+type SecurityHeaders struct {
+	CSP                       *CSP
+	HSTS                      HSTS
+	ReferrerPolicy            string
+	PermissionsPolicy         PermissionsPolicy
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+	CrossOriginResourcePolicy string
+	ReportingEndpoints        map[string]string
+	NoSniff                   bool
+	FrameOptions              string
+	XSSProtection             string
+	CacheControl              string
+	Routes                    map[string]SecurityHeaders
+}
+
+// LegacySecurityHeaders returns the headers added by AddSecurityHeaders.
+//
+// Returns:
+// - SecurityHeaders: The configuration of AddSecurityHeaders, to be used as a starting point.
+func LegacySecurityHeaders() SecurityHeaders {
+	csp := CSP{}.With("frame-ancestors", "'none'")
+	return SecurityHeaders{
+		CSP:           &csp,
+		HSTS:          HSTS{MaxAge: 365 * 24 * time.Hour, IncludeSubDomains: true, Preload: true},
+		NoSniff:       true,
+		FrameOptions:  "deny",
+		XSSProtection: "1; mode=block",
+		CacheControl:  "no-store",
+	}
+}
 
 // AddSecurityHeaders is a middleware function that adds security headers to the HTTP response.
 // It takes a http.Handler as an argument which represents the next handler to be executed in the middleware chain.
@@ -11,6 +204,7 @@ import "net/http"
 // - Content-Security-Policy: This header is used to prevent a wide range of attacks, including Cross-site scripting and other cross-site injections.
 // - X-XSS-Protection: This header is used to configure the XSS Auditor in Chrome, Internet Explorer and Safari (though it's being deprecated).
 // - Cache-Control: This header is used to specify directives for caching mechanisms in both requests and responses.
+// Use SecurityHeaders to configure the headers.
 //
 // Parameters:
 // next: The next http.Handler to be executed in the middleware chain.
@@ -18,14 +212,89 @@ import "net/http"
 // Returns:
 // A http.Handler that can be used in the middleware chain.
 func AddSecurityHeaders(next http.Handler) http.Handler {
+	return LegacySecurityHeaders().Handler(next)
+}
+
+// Handler is a middleware function that adds the configured security headers to the HTTP response.
+// When the CSP has NonceSource, a nonce is generated for every request and stored in the request context,
+// where CSPNonce reads it for the inline scripts and styles of the response.
+//
+// Parameters:
+// - next: The next http.Handler to call in the middleware chain.
+//
+// Returns:
+// - An http.Handler that adds the headers and then calls the next handler.
+func (s SecurityHeaders) Handler(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "deny")
-		w.Header().Set("Content-Security-Policy", "frame-ancestors 'none';")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Cache-Control", "no-store")
+		headers := s.route(r)
+
+		if headers.CSP != nil {
+			var nonce string
+			if headers.CSP.usesNonce() {
+				b := make([]byte, 16)
+				rand.Read(b) //nolint:errcheck
+				nonce = base64.StdEncoding.EncodeToString(b)
+				r = r.WithContext(context.WithValue(r.Context(), web.CSPNonce, nonce))
+			}
+
+			name := "Content-Security-Policy"
+			if headers.CSP.ReportOnly {
+				name = "Content-Security-Policy-Report-Only"
+			}
+			w.Header().Set(name, headers.CSP.String(nonce))
+		}
+
+		setHeader(w, "Strict-Transport-Security", headers.HSTS.MaxAge > 0, headers.HSTS.String())
+		setHeader(w, "Referrer-Policy", headers.ReferrerPolicy != "", headers.ReferrerPolicy)
+		setHeader(w, "Permissions-Policy", len(headers.PermissionsPolicy) > 0, headers.PermissionsPolicy.String())
+		setHeader(w, "Cross-Origin-Opener-Policy", headers.CrossOriginOpenerPolicy != "", headers.CrossOriginOpenerPolicy)
+		setHeader(w, "Cross-Origin-Embedder-Policy", headers.CrossOriginEmbedderPolicy != "", headers.CrossOriginEmbedderPolicy)
+		setHeader(w, "Cross-Origin-Resource-Policy", headers.CrossOriginResourcePolicy != "", headers.CrossOriginResourcePolicy)
+		setHeader(w, "X-Content-Type-Options", headers.NoSniff, "nosniff")
+		setHeader(w, "X-Frame-Options", headers.FrameOptions != "", headers.FrameOptions)
+		setHeader(w, "X-XSS-Protection", headers.XSSProtection != "", headers.XSSProtection)
+		setHeader(w, "Cache-Control", headers.CacheControl != "", headers.CacheControl)
+
+		if len(headers.ReportingEndpoints) > 0 {
+			groups := make([]string, 0, len(headers.ReportingEndpoints))
+			for group, endpoint := range headers.ReportingEndpoints {
+				groups = append(groups, fmt.Sprintf("%s=%q", group, endpoint))
+			}
+			sort.Strings(groups)
+			w.Header().Set("Reporting-Endpoints", strings.Join(groups, ", "))
+		}
+
 		next.ServeHTTP(w, r)
 	}
 	return http.HandlerFunc(fn)
 }
+
+// route returns the headers of the route pattern that matches the request.
+func (s SecurityHeaders) route(r *http.Request) SecurityHeaders {
+	if len(s.Routes) == 0 {
+		return s
+	}
+	if headers, ok := s.Routes[matchRoute(r)]; ok {
+		return headers
+	}
+	return s
+}
+
+func setHeader(w http.ResponseWriter, name string, ok bool, value string) {
+	if ok {
+		w.Header().Set(name, value)
+	}
+}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the request, or an empty string when
+// the policy does not use NonceSource.
+//
+// Parameters:
+// - ctx: The context of the request.
+//
+// Returns:
+// - string: The nonce to set on inline scripts and styles, e.g. <script nonce="...">.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(web.CSPNonce).(string)
+	return nonce
+}
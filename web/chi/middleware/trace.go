@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// TraceContext is a middleware function that continues the W3C Trace Context of the caller.
+// It parses the traceparent and tracestate headers and starts a span of this service in the caller's trace,
+// or starts a new trace when the request has no valid traceparent. The trace context is stored in the request
+// context, where web.TraceFromContext reads it, the web/json functions send its IDs in the X-Trace-Id and
+// X-Span-Id response headers, and the logger middleware functions log them. web.InjectTrace and
+// web.TraceTransport propagate it to outgoing requests.
+//
+// The function takes the next http.Handler to call in the middleware chain.
+// It returns a new http.Handler that stores the trace context and then calls the next handler.
+func TraceContext(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		tc := web.NewTraceContext()
+		if parent, ok := web.ParseTraceparent(r.Header.Get(web.Traceparent)); ok {
+			parent.State = strings.Join(r.Header.Values(web.Tracestate), ",")
+			tc = parent.Child()
+		}
+
+		next.ServeHTTP(w, r.WithContext(web.ContextWithTrace(r.Context(), tc)))
+	}
+	return http.HandlerFunc(fn)
+}
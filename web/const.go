@@ -3,8 +3,16 @@ package web
 const (
 	XServiceName    = "X-Service-Name"
 	XServiceVersion = "X-Service-Version"
+	XTraceID        = "X-Trace-Id"
+	XSpanID         = "X-Span-Id"
 
 	ServiceName    = "service"
 	ServiceVersion = "version"
 	RequestID      = "requestId"
+	MediaType      = "mediaType"
+	Trace          = "trace"
+	TraceID        = "traceId"
+	SpanID         = "spanId"
+	CSPNonce       = "cspNonce"
+	Claims         = "claims"
 )
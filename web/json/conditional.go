@@ -0,0 +1,168 @@
+package json
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Validators are the representation metadata used for conditional requests.
+// It contains the following fields:
+// - ETag: the entity tag of the resource, e.g. its version; generated from the encoded body when empty
+// - Weak: whether the entity tag is weak, meaning representations with the same tag are only semantically equivalent
+// - LastModified: when the resource was last changed, not used when zero
+type Validators struct {
+	ETag         string
+	Weak         bool
+	LastModified time.Time
+}
+
+// entityTag returns the quoted entity tag of the validators.
+func (v Validators) entityTag() string {
+	if v.ETag == "" {
+		return ""
+	}
+
+	tag := v.ETag
+	if !strings.HasPrefix(tag, `"`) && !strings.HasPrefix(tag, `W/"`) {
+		tag = `"` + tag + `"`
+	}
+	if v.Weak && !strings.HasPrefix(tag, "W/") {
+		tag = "W/" + tag
+	}
+	return tag
+}
+
+func (v Validators) setHeaders(w http.ResponseWriter) {
+	if tag := v.entityTag(); tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+	if !v.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ConditionalDataResponseOf sends a successful JSON response with typed data, or http.StatusNotModified
+// without a body when the client already has the current representation.
+// It sets the request ID header, service name, and service version in the response headers, along with the
+// ETag and Last-Modified headers. When the validators have no ETag, a strong or weak one is generated from the
+// encoded body. GET and HEAD requests whose If-None-Match header matches the ETag, or whose If-Modified-Since
+// header is not older than the last modification when there is no If-None-Match header, get http.StatusNotModified.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - status: The HTTP status code to set in the response when it is sent.
+// - data: The data to include in the response.
+// - validators: The ETag and last modification of the data.
+func ConditionalDataResponseOf[T any](w http.ResponseWriter, r *http.Request, status int, data T, validators Validators) {
+	Default().conditional(w, r, status, Envelope[T]{Status: StatusSuccess, Data: data}, validators)
+}
+
+// ConditionalData sends a successful response with data, or http.StatusNotModified. See ConditionalDataResponseOf.
+func (rs *Responder) ConditionalData(w http.ResponseWriter, r *http.Request, status int, data any, validators Validators) {
+	rs.conditional(w, r, status, Envelope[any]{Status: StatusSuccess, Data: data}, validators)
+}
+
+func (rs *Responder) conditional(w http.ResponseWriter, r *http.Request, status int, envelope any, validators Validators) {
+	rs.setHeaders(w, r)
+
+	mediaType, body, ok := rs.encode(w, r, status, envelope)
+	if !ok {
+		return
+	}
+	if validators.ETag == "" {
+		sum := sha256.Sum256(body)
+		validators.ETag = base64.RawURLEncoding.EncodeToString(sum[:16])
+	}
+	validators.setHeaders(w)
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && notModified(r, validators) {
+		rs.send(w, r, http.StatusNotModified, "", nil)
+		return
+	}
+
+	rs.send(w, r, status, mediaType, body)
+}
+
+// CheckPreconditions evaluates the If-Match and If-Unmodified-Since headers of a request that changes a resource,
+// so a client cannot overwrite changes it has not seen. When a precondition fails, it sends a failed response with
+// a status of http.StatusPreconditionFailed and returns false. The handler must return without writing anything else.
+// The validators must describe the current state of the resource and should use a caller-provided ETag such as a version,
+// because the entity tags generated by ConditionalDataResponseOf depend on the negotiated encoding.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the failed response to.
+// - r: The http.Request to check.
+// - current: The ETag and last modification of the resource, an empty ETag when the resource does not exist.
+//
+// Returns:
+// - bool: Whether the request may proceed.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, current Validators) bool {
+	return Default().CheckPreconditions(w, r, current)
+}
+
+// CheckPreconditions evaluates the If-Match and If-Unmodified-Since headers of a request.
+// See the package-level CheckPreconditions.
+func (rs *Responder) CheckPreconditions(w http.ResponseWriter, r *http.Request, current Validators) bool {
+	message := ""
+	if header := r.Header.Get("If-Match"); header != "" {
+		if !matchETag(header, current.entityTag(), false) {
+			message = "If-Match does not match the current ETag of the resource"
+		}
+	} else if header := r.Header.Get("If-Unmodified-Since"); header != "" && !current.LastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err == nil && current.LastModified.Truncate(time.Second).After(since) {
+			message = "resource has been modified since If-Unmodified-Since"
+		}
+	}
+
+	if message != "" {
+		current.setHeaders(w)
+		rs.Failed(w, r, http.StatusPreconditionFailed, []map[string]any{
+			{
+				"message": message,
+			},
+		})
+		return false
+	}
+	return true
+}
+
+func notModified(r *http.Request, validators Validators) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return matchETag(header, validators.entityTag(), true)
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !validators.LastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !validators.LastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// matchETag reports whether a list of entity tags from If-Match or If-None-Match matches the current tag,
+// using the weak comparison for If-None-Match and the strong comparison for If-Match.
+func matchETag(header, current string, weak bool) bool {
+	if current == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	currentWeak := strings.HasPrefix(current, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		tagWeak := strings.HasPrefix(tag, "W/")
+		if !weak && (tagWeak || currentWeak) {
+			continue
+		}
+		if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(current, "W/") {
+			return true
+		}
+	}
+	return false
+}
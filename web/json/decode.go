@@ -0,0 +1,237 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the request body limit used by DecodeJSON.
+const DefaultMaxBodyBytes = 1 << 20
+
+// DecodeConfig holds the settings used to decode request bodies.
+// It contains the following fields:
+// - MaxBodyBytes: the largest accepted body in bytes, DefaultMaxBodyBytes when zero
+// - AllowUnknownFields: whether members that do not match a field of the target are ignored instead of rejected
+type DecodeConfig struct {
+	MaxBodyBytes       int64
+	AllowUnknownFields bool
+}
+
+// DecodeJSON decodes and validates the JSON body of the request with the default DecodeConfig.
+// See DecodeJSONWith for details.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the failed response to.
+// - r: The http.Request to read the body from.
+//
+// Returns:
+// - T: The decoded value.
+// - bool: Whether the body is valid. When false, a failed response has been sent.
+func DecodeJSON[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	return DecodeJSONWith[T](w, r, DecodeConfig{})
+}
+
+// DecodeJSONWith decodes and validates the JSON body of the request.
+// The body must be a single JSON document no larger than the configured limit, and unknown fields are rejected
+// unless allowed. The decoded value is then checked with Validate.
+// On failure, it sends a failed response with one FieldError per problem and returns false:
+// - http.StatusRequestEntityTooLarge when the body is too large
+// - http.StatusBadRequest when the body is empty, malformed, has unknown fields or trailing data
+// - http.StatusUnprocessableEntity when the body is well-formed but fails validation
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the failed response to.
+// - r: The http.Request to read the body from.
+// - config: The body limit and unknown field policy.
+//
+// Returns:
+// - T: The decoded value.
+// - bool: Whether the body is valid. When false, a failed response has been sent.
+func DecodeJSONWith[T any](w http.ResponseWriter, r *http.Request, config DecodeConfig) (T, bool) {
+	var v T
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	status, errs := decodeBody(w, r, &v, config)
+	if errs == nil {
+		if err := Validate(v); err != nil {
+			status, errs = http.StatusUnprocessableEntity, err.(ValidationErrors)
+		}
+	}
+	if errs != nil {
+		FailedResponseOf(w, r, status, errs)
+		var zero T
+		return zero, false
+	}
+	return v, true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, config DecodeConfig) (int, ValidationErrors) {
+	// The body is kept to find the path of unknown fields, which the decoder only reports by name.
+	body := &bytes.Buffer{}
+	dec := json.NewDecoder(io.TeeReader(http.MaxBytesReader(w, r.Body, config.MaxBodyBytes), body))
+	if !config.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		status, errs := decodeError(err, config)
+		if errs[0].Rule == "unknown" {
+			if path, ok := unknownFieldPath(body.Bytes(), reflect.TypeOf(v), errs[0].Field); ok {
+				errs[0].Field, errs[0].Message = path, fmt.Sprintf("%s is not a known field", path)
+			}
+		}
+		return status, errs
+	}
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return decodeError(err, config)
+		}
+		return http.StatusBadRequest, ValidationErrors{{
+			Rule:    "single",
+			Message: "request body must contain a single JSON document",
+		}}
+	}
+	return 0, nil
+}
+
+func decodeError(err error, config DecodeConfig) (int, ValidationErrors) {
+	var (
+		syntaxError   *json.SyntaxError
+		typeError     *json.UnmarshalTypeError
+		maxBytesError *http.MaxBytesError
+		fieldError    FieldError
+		status        = http.StatusBadRequest
+		unknownField  = "json: unknown field "
+		errorMessage  = err.Error()
+	)
+
+	switch {
+	case errors.As(err, &maxBytesError):
+		status = http.StatusRequestEntityTooLarge
+		fieldError = FieldError{Rule: "size", Message: fmt.Sprintf("request body must not be larger than %d bytes", config.MaxBodyBytes)}
+	case errors.Is(err, io.EOF):
+		fieldError = FieldError{Rule: "required", Message: "request body is empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		fieldError = FieldError{Rule: "syntax", Message: "request body contains malformed JSON"}
+	case errors.As(err, &syntaxError):
+		fieldError = FieldError{Rule: "syntax", Message: fmt.Sprintf("request body contains malformed JSON at position %d", syntaxError.Offset)}
+	case errors.As(err, &typeError):
+		subject := typeError.Field
+		if subject == "" {
+			subject = "request body"
+		}
+		fieldError = FieldError{Field: typeError.Field, Rule: "type", Message: fmt.Sprintf("%s must be %s", subject, jsonKind(typeError.Type))}
+	case strings.HasPrefix(errorMessage, unknownField):
+		field := strings.Trim(strings.TrimPrefix(errorMessage, unknownField), `"`)
+		fieldError = FieldError{Field: field, Rule: "unknown", Message: fmt.Sprintf("%s is not a known field", field)}
+	default:
+		fieldError = FieldError{Rule: "syntax", Message: errorMessage}
+	}
+
+	return status, ValidationErrors{fieldError}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// jsonKind describes the JSON value expected for a Go type, so type errors do not expose Go type names.
+func jsonKind(typ reflect.Type) string {
+	if typ == nil {
+		return "a valid value"
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "a base64 string"
+		}
+		return "an array"
+	case reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a valid value"
+	}
+}
+
+// unknownFieldPath finds the path of the first member named name that does not match a field of the type,
+// in the format of Validate, e.g. items[0].qty.
+func unknownFieldPath(body []byte, typ reflect.Type, name string) (string, bool) {
+	var doc any
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&doc); err != nil {
+		return "", false
+	}
+	return findUnknownField(doc, typ, name, "")
+}
+
+func findUnknownField(doc any, typ reflect.Type, name, path string) (string, bool) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch value := doc.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			var member reflect.Type
+			switch typ.Kind() {
+			case reflect.Map:
+				member = typ.Elem()
+			case reflect.Struct:
+				field, ok := lookupField(typ, key)
+				if !ok {
+					if key == name {
+						return joinPath(path, key), true
+					}
+					continue
+				}
+				member = field
+			default:
+				return "", false
+			}
+			if found, ok := findUnknownField(value[key], member, name, joinPath(path, key)); ok {
+				return found, true
+			}
+		}
+	case []any:
+		if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+			return "", false
+		}
+		for i, item := range value {
+			if found, ok := findUnknownField(item, typ.Elem(), name, fmt.Sprintf("%s[%d]", path, i)); ok {
+				return found, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,114 @@
+package json
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// Deprecation describes the lifecycle of a deprecated endpoint.
+// It contains the following fields:
+// - Since: when the endpoint was deprecated, the Deprecation header is "true" when zero
+// - Sunset: when the endpoint stops being available, no Sunset header is sent when zero
+// - Link: the documentation of the deprecation, sent as a Link header with rel="deprecation" when not empty
+// - Gone: whether requests are rejected with http.StatusGone after the sunset
+// - Caller: identifies the client in the warning logs, the remote address is used when nil
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	Link   string
+	Gone   bool
+	Caller func(r *http.Request) string
+}
+
+// Apply sets the deprecation headers, logs a warning about the use of the deprecated endpoint and,
+// when Gone is set and the sunset has passed, sends a failed response with a status of http.StatusGone.
+//
+// Parameters:
+// - w: The http.ResponseWriter to set the headers on.
+// - r: The http.Request of the deprecated endpoint.
+//
+// Returns:
+// - bool: false when the response has been sent, in which case the request must not be processed further.
+func (d Deprecation) Apply(w http.ResponseWriter, r *http.Request) bool {
+	d.setHeaders(w)
+	d.log(r)
+
+	if d.gone(time.Now()) {
+		Default().Failed(w, r, http.StatusGone, d.goneMessages())
+		return false
+	}
+	return true
+}
+
+// setHeaders sets the Deprecation, Sunset and Link headers.
+func (d Deprecation) setHeaders(w http.ResponseWriter) {
+	if d.Since.IsZero() {
+		w.Header().Set("Deprecation", "true")
+	} else {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	}
+	if !d.Sunset.IsZero() {
+		w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Link != "" {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", d.Link))
+	}
+}
+
+// log writes a warning with the request ID, the route and the caller of the deprecated endpoint.
+func (d Deprecation) log(r *http.Request) {
+	caller := r.RemoteAddr
+	if d.Caller != nil {
+		caller = d.Caller(r)
+	}
+
+	route := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+
+	event := log.Warn().Str(web.RequestID, middleware.GetReqID(r.Context())).
+		Str("method", r.Method).Str("route", route).Str("caller", caller).
+		Str("user_agent", r.UserAgent())
+	if !d.Sunset.IsZero() {
+		event = event.Time("sunset", d.Sunset)
+	}
+	event.Msg("Deprecated endpoint called")
+}
+
+// gone reports whether requests must be rejected at the given time.
+func (d Deprecation) gone(now time.Time) bool {
+	return d.Gone && !d.Sunset.IsZero() && !now.Before(d.Sunset)
+}
+
+func (d Deprecation) goneMessages() []map[string]any {
+	return []map[string]any{
+		{
+			"message": fmt.Sprintf("endpoint is no longer available since %s", d.Sunset.UTC().Format(time.RFC3339)),
+		},
+	}
+}
+
+// Deprecated returns a copy of the Responder that marks its responses as deprecated.
+// Every response sets the headers and logs the warning of Deprecation.Apply, and successful responses
+// are replaced with a failed response with a status of http.StatusGone when Gone is set and the sunset has passed.
+// It is meant to be created once per deprecated route, e.g. when registering the handler.
+//
+// Parameters:
+// - d: The Deprecation of the route.
+//
+// Returns:
+// - *Responder: The Responder used by the handlers of the deprecated route.
+func (rs *Responder) Deprecated(d Deprecation) *Responder {
+	deprecated := *rs
+	deprecated.Deprecation = &d
+	return &deprecated
+}
@@ -0,0 +1,289 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// Media types of the built-in encoders.
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeXML         = "application/xml"
+	ContentTypeMessagePack = "application/msgpack"
+	ContentTypeCBOR        = "application/cbor"
+	ContentTypeProblemXML  = "application/problem+xml"
+)
+
+// Encoder encodes a response document into the body of a response.
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+// EncoderFunc is an adapter to allow the use of ordinary functions as an Encoder.
+type EncoderFunc func(w io.Writer, v any) error
+
+// Encode calls f(w, v).
+func (f EncoderFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+type registeredEncoder struct {
+	mediaType string
+	encoder   Encoder
+}
+
+// Built-in encoders. JSONEncoder is always registered, the others are registered by RegisterBuiltinEncoders.
+var (
+	JSONEncoder        Encoder = EncoderFunc(encodeJSON)
+	XMLEncoder         Encoder = EncoderFunc(encodeXML)
+	MessagePackEncoder Encoder = EncoderFunc(encodeMessagePack)
+	CBOREncoder        Encoder = EncoderFunc(encodeCBOR)
+)
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = []registeredEncoder{
+		{mediaType: ContentTypeJSON, encoder: JSONEncoder},
+	}
+)
+
+// RegisterBuiltinEncoders registers XMLEncoder for application/xml and text/xml, MessagePackEncoder for
+// application/msgpack, application/x-msgpack and application/vnd.msgpack, and CBOREncoder for application/cbor.
+// They are not registered by default because browsers navigating to a URL accept application/xml with a higher
+// quality than */*, so they would receive XML instead of JSON.
+func RegisterBuiltinEncoders() {
+	RegisterEncoder(ContentTypeXML, XMLEncoder)
+	RegisterEncoder("text/xml", XMLEncoder)
+	RegisterEncoder(ContentTypeMessagePack, MessagePackEncoder)
+	RegisterEncoder("application/x-msgpack", MessagePackEncoder)
+	RegisterEncoder("application/vnd.msgpack", MessagePackEncoder)
+	RegisterEncoder(ContentTypeCBOR, CBOREncoder)
+}
+
+// RegisterEncoder adds an encoder for a media type, or replaces the encoder already registered for it.
+// The response helpers choose the encoder from the Accept header of the request, preferring encoders in
+// registration order when the client has no preference. The JSON encoder is always the first one.
+//
+// Parameters:
+// - mediaType: The media type produced by the encoder, used as the Content-Type of the response.
+// - encoder: The Encoder used for the media type.
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	mediaType = strings.ToLower(mediaType)
+	for i, registered := range encoders {
+		if registered.mediaType == mediaType {
+			encoders[i].encoder = encoder
+			return
+		}
+	}
+	encoders = append(encoders, registeredEncoder{mediaType: mediaType, encoder: encoder})
+}
+
+// negotiate selects the encoder for the response from the Accept header of the request, or from the media type
+// chosen by the Accept middleware when it can be encoded.
+// The JSON and XML encoders produce the given media types, so documents with their own media type
+// such as problem details can still be negotiated with the generic ones.
+func negotiate(r *http.Request, jsonType, xmlType string) (string, Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	aliases := map[string]string{ContentTypeJSON: jsonType, ContentTypeXML: xmlType}
+	offers := make([]string, 0, len(encoders)+2)
+	byMediaType := make(map[string]Encoder, len(encoders)+2)
+	for _, registered := range encoders {
+		mediaType := registered.mediaType
+		if alias, ok := aliases[mediaType]; ok {
+			mediaType = alias
+		}
+		offers = append(offers, mediaType)
+		byMediaType[mediaType] = registered.encoder
+	}
+	for _, generic := range []string{ContentTypeJSON, ContentTypeXML} {
+		if _, registered := byMediaType[aliases[generic]]; registered && aliases[generic] != generic {
+			offers = append(offers, generic)
+		}
+	}
+
+	mediaType := web.Negotiate(r.Header.Get("Accept"), offers...)
+	if accepted, ok := r.Context().Value(web.MediaType).(string); ok && accepted != "" {
+		if chosen := web.Negotiate(accepted, offers...); chosen != "" {
+			mediaType = chosen
+		}
+	}
+	if mediaType == "" {
+		return "", nil, false
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		mediaType = alias
+	}
+	return mediaType, byMediaType[mediaType], true
+}
+
+// jsonEncoder returns the encoder registered for ContentTypeJSON, used when error responses cannot be negotiated.
+func jsonEncoder() Encoder {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	return encoders[0].encoder
+}
+
+// notAcceptable returns the body of the http.StatusNotAcceptable response, which lists the supported media types.
+func notAcceptable() []byte {
+	encodersMu.RLock()
+	mediaTypes := make([]string, 0, len(encoders))
+	for _, registered := range encoders {
+		mediaTypes = append(mediaTypes, registered.mediaType)
+	}
+	encodersMu.RUnlock()
+
+	buf := &bytes.Buffer{}
+	encodeJSON(buf, Envelope[[]map[string]any]{ //nolint:errcheck
+		Status: StatusFailed,
+		Data: []map[string]any{
+			{
+				"message": "Accept header does not allow any of the supported media types",
+				"accept":  mediaTypes,
+			},
+		},
+	})
+	return buf.Bytes()
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(true)
+	return enc.Encode(v)
+}
+
+func encodeMessagePack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func encodeCBOR(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+// problemDocument is the document of a Problem, written by encodeXML in the format of RFC 9457 Appendix B.
+type problemDocument map[string]any
+
+// problemNamespace is the XML namespace of problem details documents.
+const problemNamespace = "urn:ietf:rfc:7807"
+
+// encodeXML encodes v as XML by converting its JSON representation into elements, so maps and
+// json struct tags produce the same structure as the JSON responses. Objects become nested elements,
+// arrays become repeated <item> elements and the document element is <response>.
+// Problem details are written as <problem xmlns="urn:ietf:rfc:7807"> with <i> array elements instead.
+func encodeXML(w io.Writer, v any) error {
+	root, item := xml.StartElement{Name: xml.Name{Local: "response"}}, "item"
+	if _, ok := v.(problemDocument); ok {
+		root = xml.StartElement{Name: xml.Name{Local: "problem"}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: problemNamespace}}}
+		item = "i"
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := encodeXMLElement(enc, root, item, doc); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func encodeXMLElement(enc *xml.Encoder, start xml.StartElement, item string, v any) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := encodeXMLElement(enc, xmlElement(key), item, value[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, element := range value {
+			if err := encodeXMLElement(enc, xmlElement(item), item, element); err != nil {
+				return err
+			}
+		}
+	case nil:
+	case string:
+		if err := enc.EncodeToken(xml.CharData(value)); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := enc.EncodeToken(xml.CharData(value.String())); err != nil {
+			return err
+		}
+	case bool:
+		text := "false"
+		if value {
+			text = "true"
+		}
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+func xmlElement(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+}
+
+// xmlName turns a JSON member name into a valid XML element name.
+func xmlName(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case unicode.IsLetter(c) || c == '_':
+			b.WriteRune(c)
+		case i == 0 && unicode.IsDigit(c):
+			b.WriteRune('_')
+			b.WriteRune(c)
+		case i > 0 && (unicode.IsDigit(c) || c == '-' || c == '.'):
+			b.WriteRune(c)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
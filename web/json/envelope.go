@@ -0,0 +1,63 @@
+package json
+
+import (
+	"net/http"
+)
+
+// Status values of the response envelope.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusError   = "error"
+)
+
+// Envelope is the typed document written by the response helpers.
+// It contains the following fields:
+// - Status: one of StatusSuccess, StatusFailed or StatusError
+// - Data: the payload of successful and failed responses, omitted when empty
+// - Message: the message of error responses, omitted when empty
+// - Pagination: the paging metadata of list responses, omitted when empty
+type Envelope[T any] struct {
+	Status     string `json:"status"`
+	Data       T      `json:"data,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Pagination Page   `json:"pagination,omitempty"`
+}
+
+// envelope is implemented by Envelope, so a Responder can render it in other styles.
+type envelope interface {
+	fields() (status string, data any, message string, page Page)
+}
+
+func (e Envelope[T]) fields() (string, any, string, Page) {
+	return e.Status, e.Data, e.Message, e.Pagination
+}
+
+// DataResponseOf sends a successful JSON response with typed data.
+// It sets the request ID header, service name, and service version in the response headers.
+// Then it sets the status of the response and sends an Envelope with a status of "success" and the provided data.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - status: The HTTP status code to set in the response.
+// - data: The data to include in the response, any struct, slice or map that can be encoded.
+func DataResponseOf[T any](w http.ResponseWriter, r *http.Request, status int, data T) {
+	rs := Default()
+	rs.setHeaders(w, r)
+	rs.write(w, r, status, Envelope[T]{Status: StatusSuccess, Data: data})
+}
+
+// FailedResponseOf sends a failed JSON response with typed data.
+// It sets the request ID header, service name, and service version in the response headers.
+// Then it sets the status of the response and sends an Envelope with a status of "failed" and the provided data.
+// When the error format is FormatProblem, the data is sent as the "errors" member of a problem details document instead.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - status: The HTTP status code to set in the response.
+// - data: The data to include in the response, usually a slice describing what is wrong with the request.
+func FailedResponseOf[T any](w http.ResponseWriter, r *http.Request, status int, data T) {
+	Default().failed(w, r, status, data, Envelope[T]{Status: StatusFailed, Data: data})
+}
@@ -0,0 +1,231 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+
+	"github.com/dynastymasra/go-library/db/postgres"
+	"github.com/dynastymasra/go-library/web"
+)
+
+// Kind is the category of an application error, which decides the HTTP status of the response.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindRateLimited
+)
+
+// Status returns the HTTP status code of the kind.
+func (k Kind) Status() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is an application error that HandleError turns into a response.
+// It contains the following fields:
+// - Kind: the category of the error
+// - Message: the message sent to the client, never sent for internal errors
+// - Fields: the invalid fields of validation errors
+// - RetryAfter: when the client may retry a rate limited request, not sent when zero
+// - Err: the underlying cause, logged but never sent to the client
+type Error struct {
+	Kind       Kind
+	Message    string
+	Fields     ValidationErrors
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error returns the message and the cause of the error.
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap sets the cause of the error and returns the error.
+//
+// Parameters:
+// - err: The underlying cause.
+//
+// Returns:
+// - *Error: The same error with its cause set.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// BadRequest creates an error for requests that cannot be processed.
+func BadRequest(message string) *Error {
+	return &Error{Kind: KindBadRequest, Message: message}
+}
+
+// NotFound creates an error for resources that do not exist.
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// Conflict creates an error for requests that conflict with the current state of a resource.
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// Validation creates an error for requests with invalid fields.
+func Validation(fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: "request contains invalid fields", Fields: fields}
+}
+
+// Unauthorized creates an error for requests without valid credentials.
+func Unauthorized(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+// Forbidden creates an error for requests whose credentials do not grant access.
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+// RateLimited creates an error for clients that sent too many requests.
+func RateLimited(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindRateLimited, Message: message, RetryAfter: retryAfter}
+}
+
+// Internal creates an error for unexpected failures. Its cause is logged but not sent to the client.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Err: err}
+}
+
+// HandleError sends the response for an error returned by the application.
+// It unwraps the error to find its kind:
+// - *Error uses its own kind, message and fields
+// - ValidationErrors from Validate is sent as http.StatusUnprocessableEntity with one entry per field
+// - gorm.ErrRecordNotFound and mongo.ErrNoDocuments are sent as http.StatusNotFound
+// - Postgres unique violations and Mongo duplicate key errors are sent as http.StatusConflict
+// - Postgres foreign key, not null, check and invalid text representation violations are sent as http.StatusUnprocessableEntity
+// - any other error is sent as http.StatusInternalServerError
+// Client errors are sent as failed responses. Server errors are sent as error responses with the status text only,
+// and their cause is logged at error level with the request ID.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - err: The error to send, nothing is sent when it is nil.
+func HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	Default().HandleError(w, r, err)
+}
+
+// HandleError sends the response for an error returned by the application. See the package-level HandleError.
+func (rs *Responder) HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+
+	appErr := toError(err)
+	status := appErr.Kind.Status()
+
+	if status >= http.StatusInternalServerError {
+		log.Error().Err(err).Str(web.RequestID, middleware.GetReqID(r.Context())).
+			Str("method", r.Method).Str("path", r.URL.Path).Int("status", status).Msg("Request failed")
+		rs.Error(w, r, status, http.StatusText(status))
+		return
+	}
+
+	log.Debug().Err(err).Str(web.RequestID, middleware.GetReqID(r.Context())).
+		Str("method", r.Method).Str("path", r.URL.Path).Int("status", status).Msg("Request rejected")
+
+	if appErr.RetryAfter > 0 {
+		seconds := int((appErr.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	if len(appErr.Fields) > 0 {
+		rs.Failed(w, r, status, appErr.Fields)
+		return
+	}
+
+	message := appErr.Message
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	rs.Failed(w, r, status, []map[string]any{
+		{
+			"message": message,
+		},
+	})
+}
+
+// toError maps an error to the application error that describes it.
+func toError(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fields ValidationErrors
+	if errors.As(err, &fields) {
+		return Validation(fields...)
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments) {
+		return NotFound("resource is not found")
+	}
+
+	if postgres.IsUniqueViolation(err) || mongo.IsDuplicateKeyError(err) {
+		return Conflict("resource already exists")
+	}
+
+	switch {
+	case postgres.IsForeignKeyViolation(err):
+		return &Error{Kind: KindValidation, Message: "referenced resource does not exist"}
+	case postgres.IsNotNullViolation(err):
+		// The column is not sent because it does not have to match a field of the request.
+		return &Error{Kind: KindValidation, Message: "request is missing a required value"}
+	case postgres.IsCheckViolation(err):
+		return &Error{Kind: KindValidation, Message: "resource does not satisfy a constraint"}
+	case postgres.IsInvalidTextRepresentation(err):
+		return &Error{Kind: KindValidation, Message: "request contains a value with an invalid format"}
+	}
+
+	return Internal(err)
+}
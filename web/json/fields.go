@@ -0,0 +1,239 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldsParam is the query parameter that selects the fields of a response, e.g. ?fields=id,name,owner.email
+const FieldsParam = "fields"
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// ParseFields returns the field paths selected by the fields query parameter of the request.
+//
+// Parameters:
+// - r: The http.Request to read the query parameter from.
+//
+// Returns:
+// - []string: The dot separated field paths, or nil when the request does not select fields.
+func ParseFields(r *http.Request) []string {
+	var fields []string
+	for _, value := range r.URL.Query()[FieldsParam] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// Project keeps only the selected fields of data, as it would be encoded to JSON.
+// Paths are dot separated JSON names, nested objects are selected with their own paths, e.g. "owner.email",
+// and paths into arrays apply to every element. Selecting an object keeps all of its fields.
+//
+// Parameters:
+// - data: The value to project.
+// - fields: The field paths to keep.
+//
+// Returns:
+// - any: The projected value, made of maps, slices and JSON scalars.
+// - error: An error naming the first field that does not exist in the type of data.
+func Project(data any, fields []string) (any, error) {
+	tree := map[string]any{}
+	for _, field := range fields {
+		if err := checkField(reflect.TypeOf(data), strings.Split(field, ".")); err != nil {
+			return nil, fmt.Errorf("%s is not a known field", field)
+		}
+
+		node := tree
+		parts := strings.Split(field, ".")
+		for i, part := range parts {
+			child, ok := node[part].(map[string]any)
+			if _, selected := node[part]; selected && !ok {
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = true
+				break
+			}
+			if !ok {
+				child = map[string]any{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return project(restoreNumbers(doc), tree), nil
+}
+
+// restoreNumbers converts the numbers of a decoded document back to int64, uint64, or float64 when they are not integers,
+// so encoders other than JSON write them as numbers instead of strings.
+func restoreNumbers(doc any) any {
+	switch value := doc.(type) {
+	case map[string]any:
+		for key, member := range value {
+			value[key] = restoreNumbers(member)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = restoreNumbers(item)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(value.String(), 10, 64); err == nil {
+			return n
+		}
+		if f, err := value.Float64(); err == nil {
+			return f
+		}
+	}
+	return doc
+}
+
+func project(doc any, tree map[string]any) any {
+	switch value := doc.(type) {
+	case map[string]any:
+		projected := make(map[string]any, len(tree))
+		for key, selection := range tree {
+			member, ok := value[key]
+			if !ok {
+				continue
+			}
+			if subtree, ok := selection.(map[string]any); ok {
+				projected[key] = project(member, subtree)
+			} else {
+				projected[key] = member
+			}
+		}
+		return projected
+	case []any:
+		projected := make([]any, 0, len(value))
+		for _, item := range value {
+			projected = append(projected, project(item, tree))
+		}
+		return projected
+	default:
+		return doc
+	}
+}
+
+// checkField reports an error when a path does not exist in the JSON encoding of a type.
+// Maps, interfaces and types with their own JSON encoding accept any path.
+func checkField(typ reflect.Type, path []string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	if typ == nil || path[0] == "" {
+		return fmt.Errorf("unknown field")
+	}
+
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		if typ.Implements(jsonMarshalerType) {
+			return nil
+		}
+		typ = typ.Elem()
+	}
+	if typ.Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(jsonMarshalerType) {
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Map, reflect.Interface:
+		return nil
+	case reflect.Struct:
+		field, ok := lookupField(typ, path[0])
+		if !ok {
+			return fmt.Errorf("unknown field")
+		}
+		return checkField(field, path[1:])
+	default:
+		return fmt.Errorf("unknown field")
+	}
+}
+
+// lookupField finds the type of the struct field encoded with the given JSON name, including promoted fields.
+func lookupField(typ reflect.Type, name string) (reflect.Type, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if found, ok := lookupField(embedded, name); ok {
+					return found, true
+				}
+				continue
+			}
+		}
+
+		if jsonName(field) == name {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// ProjectedDataResponseOf sends a successful JSON response with the fields of the data selected by the request.
+// It behaves like DataResponseOf when the request has no fields query parameter. When a selected field does not
+// exist, it sends a failed response with a status of http.StatusBadRequest instead.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - status: The HTTP status code to set in the response.
+// - data: The data to project and include in the response.
+func ProjectedDataResponseOf[T any](w http.ResponseWriter, r *http.Request, status int, data T) {
+	Default().ProjectedData(w, r, status, data)
+}
+
+// ProjectedData sends a successful response with the fields of the data selected by the request.
+// See ProjectedDataResponseOf. The fields query parameter is ignored with StyleJSONAPI, because projected data
+// cannot be marshaled into resources.
+func (rs *Responder) ProjectedData(w http.ResponseWriter, r *http.Request, status int, data any) {
+	fields := ParseFields(r)
+	if len(fields) == 0 || rs.Style == StyleJSONAPI {
+		rs.Data(w, r, status, data)
+		return
+	}
+
+	projected, err := Project(data, fields)
+	if err != nil {
+		rs.Failed(w, r, http.StatusBadRequest, []map[string]any{
+			{
+				"field":   FieldsParam,
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	rs.Data(w, r, status, projected)
+}
@@ -1,37 +1,26 @@
 package json
 
 import (
-	"fmt"
 	"net/http"
-
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
-
-	"github.com/dynastymasra/go-library/web"
 )
 
 // SuccessResponse sends a successful JSON response without any additional data.
 // It sets the request ID header, service name, and service version in the response headers.
 // Then it sets the status of the response and sends a JSON response with a status of "success".
+// It is a wrapper of Default().Success.
 //
 // Parameters:
 // - w: The http.ResponseWriter to write the response to.
 // - r: The http.Request that we are responding to.
 // - status: The HTTP status code to set in the response.
 func SuccessResponse(w http.ResponseWriter, r *http.Request, status int) {
-	w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
-	w.Header().Set(web.XServiceName, fmt.Sprintf("%v", r.Context().Value(web.ServiceName)))
-	w.Header().Set(web.XServiceVersion, fmt.Sprintf("%v", r.Context().Value(web.ServiceVersion)))
-
-	render.Status(r, status)
-	render.JSON(w, r, map[string]any{
-		"status": "success",
-	})
+	Default().Success(w, r, status)
 }
 
 // DataResponse sends a successful JSON response with data.
 // It sets the request ID header, service name, and service version in the response headers.
 // Then it sets the status of the response and sends a JSON response with a status of "success" and the provided data.
+// It is a wrapper of Default().Data.
 //
 // Parameters:
 // - w: The http.ResponseWriter to write the response to.
@@ -39,20 +28,14 @@ func SuccessResponse(w http.ResponseWriter, r *http.Request, status int) {
 // - status: The HTTP status code to set in the response.
 // - data: The data to include in the response.
 func DataResponse(w http.ResponseWriter, r *http.Request, status int, data map[string]any) {
-	w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
-	w.Header().Set(web.XServiceName, fmt.Sprintf("%v", r.Context().Value(web.ServiceName)))
-	w.Header().Set(web.XServiceVersion, fmt.Sprintf("%v", r.Context().Value(web.ServiceVersion)))
-
-	render.Status(r, status)
-	render.JSON(w, r, map[string]any{
-		"status": "success",
-		"data":   data,
-	})
+	Default().Data(w, r, status, data)
 }
 
 // FailedResponse sends a failed JSON response with data.
 // It sets the request ID header, service name, and service version in the response headers.
 // Then it sets the status of the response and sends a JSON response with a status of "failed" and the provided data.
+// When the error format is FormatProblem, the data is sent as the "errors" member of a problem details document instead.
+// It is a wrapper of Default().Failed.
 //
 // Parameters:
 // - w: The http.ResponseWriter to write the response to.
@@ -60,20 +43,14 @@ func DataResponse(w http.ResponseWriter, r *http.Request, status int, data map[s
 // - status: The HTTP status code to set in the response.
 // - data: The data to include in the response.
 func FailedResponse(w http.ResponseWriter, r *http.Request, status int, data []map[string]any) {
-	w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
-	w.Header().Set(web.XServiceName, fmt.Sprintf("%v", r.Context().Value(web.ServiceName)))
-	w.Header().Set(web.XServiceVersion, fmt.Sprintf("%v", r.Context().Value(web.ServiceVersion)))
-
-	render.Status(r, status)
-	render.JSON(w, r, map[string]any{
-		"status": "failed",
-		"data":   data,
-	})
+	Default().Failed(w, r, status, data)
 }
 
 // ErrorResponse sends an error JSON response with a message.
 // It sets the request ID header, service name, and service version in the response headers.
 // Then it sets the status of the response and sends a JSON response with a status of "error" and the provided message.
+// When the error format is FormatProblem, the message is sent as the detail of a problem details document instead.
+// It is a wrapper of Default().Error.
 //
 // Parameters:
 // - w: The http.ResponseWriter to write the response to.
@@ -81,13 +58,5 @@ func FailedResponse(w http.ResponseWriter, r *http.Request, status int, data []m
 // - status: The HTTP status code to set in the response.
 // - message: The error message to include in the response.
 func ErrorResponse(w http.ResponseWriter, r *http.Request, status int, message string) {
-	w.Header().Set(middleware.RequestIDHeader, middleware.GetReqID(r.Context()))
-	w.Header().Set(web.XServiceName, fmt.Sprintf("%v", r.Context().Value(web.ServiceName)))
-	w.Header().Set(web.XServiceVersion, fmt.Sprintf("%v", r.Context().Value(web.ServiceVersion)))
-
-	render.Status(r, status)
-	render.JSON(w, r, map[string]any{
-		"status":  "error",
-		"message": message,
-	})
+	Default().Error(w, r, status, message)
 }
@@ -0,0 +1,313 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ContentTypeHAL is the media type of HAL documents.
+const ContentTypeHAL = "application/hal+json"
+
+// EmbeddedItems is the relation of the items embedded by StyleHAL when the data of a response is a list.
+const EmbeddedItems = "items"
+
+// Link is a HAL link object.
+// It contains the following fields:
+// - Href: the URL or URI template of the target
+// - Templated: whether Href is a URI template
+// - Type: the media type expected when dereferencing the target, not sent when empty
+// - Name: a secondary key to select links of the same relation, not sent when empty
+// - Title: a human-readable label of the link, not sent when empty
+type Link struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// HALResource is a HAL resource. Its state is encoded with the reserved "_links" and "_embedded" members,
+// which makes it usable as the data of JSend responses as well as with StyleHAL.
+// It contains the following fields:
+// - State: the properties of the resource, it must encode to a JSON object, not sent when nil
+// - Links: the links of the resource by relation, a relation with a single link is sent as a link object
+// - Embedded: the embedded resources by relation, a HALResource, a slice of them, or any other value
+type HALResource struct {
+	State    any
+	Links    map[string][]Link
+	Embedded map[string]any
+}
+
+// AddLink adds a link to a relation of the resource.
+//
+// Parameters:
+// - rel: The relation of the link, e.g. "self" or "author".
+// - link: The Link to add.
+func (h *HALResource) AddLink(rel string, link Link) {
+	if h.Links == nil {
+		h.Links = map[string][]Link{}
+	}
+	h.Links[rel] = append(h.Links[rel], link)
+}
+
+// Embed sets the resources embedded in a relation of the resource.
+//
+// Parameters:
+// - rel: The relation of the embedded resources.
+// - v: A HALResource, a slice of them, or any other value.
+func (h *HALResource) Embed(rel string, v any) {
+	if h.Embedded == nil {
+		h.Embedded = map[string]any{}
+	}
+	h.Embedded[rel] = v
+}
+
+// MarshalJSON encodes the state of the resource with its links and embedded resources.
+func (h HALResource) MarshalJSON() ([]byte, error) {
+	doc := map[string]any{}
+	if h.State != nil {
+		b, err := json.Marshal(h.State)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil || doc == nil {
+			return nil, errors.New("hal: state must encode to a JSON object")
+		}
+	}
+
+	if len(h.Links) > 0 {
+		links := make(map[string]any, len(h.Links))
+		for rel, targets := range h.Links {
+			if len(targets) == 1 {
+				links[rel] = targets[0]
+			} else {
+				links[rel] = targets
+			}
+		}
+		doc["_links"] = links
+	}
+	if len(h.Embedded) > 0 {
+		doc["_embedded"] = h.Embedded
+	}
+	return json.Marshal(doc)
+}
+
+// LinkBuilder builds links from the route patterns of the chi router serving the request,
+// so handlers do not hardcode URLs. The zero value builds links relative to the host.
+// It contains the following fields:
+// - BaseURL: the scheme and host prepended to the links, e.g. "https://api.example.com", not used when empty
+type LinkBuilder struct {
+	BaseURL string
+}
+
+// routePatterns caches the route patterns of each router, routes are expected to be registered before serving.
+var routePatterns sync.Map
+
+// Self returns the link to the requested URL, including its query.
+//
+// Parameters:
+// - r: The http.Request that we are responding to.
+//
+// Returns:
+// - Link: The link to the request.
+func (b LinkBuilder) Self(r *http.Request) Link {
+	return Link{Href: b.BaseURL + r.URL.RequestURI()}
+}
+
+// Link returns the link to a route pattern of the router, e.g. "/users/{id}/orders".
+// Placeholders are replaced with the given parameters, and the parameters of the current route fill in
+// the others, so a handler of "/users/{id}" can link to "/users/{id}/orders" without any parameter.
+//
+// Parameters:
+// - r: The http.Request that we are responding to.
+// - pattern: The route pattern as registered in the router, including the prefixes of mounted routers.
+// - params: Pairs of placeholder names and values, e.g. "id", "42". Values are escaped.
+//
+// Returns:
+// - Link: The link to the route.
+// - error: An error if the router has no such pattern or a placeholder has no value.
+func (b LinkBuilder) Link(r *http.Request, pattern string, params ...string) (Link, error) {
+	if len(params)%2 != 0 {
+		return Link{}, errors.New("link: params must be name and value pairs")
+	}
+	if err := checkRoute(r, pattern); err != nil {
+		return Link{}, err
+	}
+
+	values := map[string]string{}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		for i, key := range rctx.URLParams.Keys {
+			values[key] = rctx.URLParams.Values[i]
+		}
+	}
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	path, err := expandPattern(pattern, func(name string) (string, error) {
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("link: no value for %s in %s", name, pattern)
+		}
+		if name == "*" {
+			return value, nil
+		}
+		return url.PathEscape(value), nil
+	})
+	if err != nil {
+		return Link{}, err
+	}
+	return Link{Href: b.BaseURL + path}, nil
+}
+
+// Template returns a templated link to a route pattern of the router, with the placeholders written
+// as URI template variables, e.g. "/users/{id:[0-9]+}" becomes "/users/{id}".
+//
+// Parameters:
+// - r: The http.Request that we are responding to.
+// - pattern: The route pattern as registered in the router.
+//
+// Returns:
+// - Link: The templated link to the route.
+// - error: An error if the router has no such pattern.
+func (b LinkBuilder) Template(r *http.Request, pattern string) (Link, error) {
+	if err := checkRoute(r, pattern); err != nil {
+		return Link{}, err
+	}
+
+	path, err := expandPattern(pattern, func(name string) (string, error) {
+		if name == "*" {
+			return "{+path}", nil
+		}
+		return "{" + name + "}", nil
+	})
+	if err != nil {
+		return Link{}, err
+	}
+	return Link{Href: b.BaseURL + path, Templated: true}, nil
+}
+
+// checkRoute reports an error when the router serving the request has no route with the pattern.
+// Requests that are not served by a chi router are not checked.
+func checkRoute(r *http.Request, pattern string) error {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return nil
+	}
+
+	cached, ok := routePatterns.Load(rctx.Routes)
+	if !ok {
+		patterns := map[string]bool{}
+		err := chi.Walk(rctx.Routes, func(_ string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			patterns[route] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cached, _ = routePatterns.LoadOrStore(rctx.Routes, patterns)
+	}
+
+	if !cached.(map[string]bool)[pattern] {
+		return fmt.Errorf("link: no route matches %s", pattern)
+	}
+	return nil
+}
+
+// expandPattern replaces the placeholders and the trailing wildcard of a chi route pattern.
+func expandPattern(pattern string, replace func(name string) (string, error)) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth, end := 0, -1
+			for j := i; j < len(pattern) && end < 0; j++ {
+				switch pattern[j] {
+				case '{':
+					depth++
+				case '}':
+					if depth--; depth == 0 {
+						end = j
+					}
+				}
+			}
+			if end < 0 {
+				return "", fmt.Errorf("link: unclosed placeholder in %s", pattern)
+			}
+
+			name, _, _ := strings.Cut(pattern[i+1:end], ":")
+			value, err := replace(name)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+			i = end
+		case '*':
+			value, err := replace("*")
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(value)
+		default:
+			sb.WriteByte(pattern[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// halDocument converts the envelope of a successful response into a HAL resource with a self link.
+// Lists are embedded as EmbeddedItems and the paging metadata becomes the state, with the pagination links.
+// Failed and error responses keep their envelope, HAL does not define error documents.
+func halDocument(r *http.Request, env envelope) any {
+	status, data, _, page := env.fields()
+	if status != StatusSuccess {
+		return env
+	}
+
+	var resource HALResource
+	switch value := data.(type) {
+	case HALResource:
+		resource = value
+	case *HALResource:
+		if value != nil {
+			resource = *value
+		}
+	case nil:
+	default:
+		if kind := reflect.ValueOf(data).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			resource.Embed(EmbeddedItems, data)
+		} else {
+			resource.State = data
+		}
+	}
+
+	links := make(map[string][]Link, len(resource.Links)+1)
+	for rel, targets := range resource.Links {
+		links[rel] = targets
+	}
+	resource.Links = links
+
+	if _, ok := resource.Links["self"]; !ok {
+		resource.AddLink("self", LinkBuilder{}.Self(r))
+	}
+	if page != nil {
+		for rel, target := range page.links(*r.URL) {
+			resource.Links[rel] = []Link{{Href: target}}
+		}
+		if resource.State == nil {
+			resource.State = page
+		}
+	}
+	return resource
+}
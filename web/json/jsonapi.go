@@ -0,0 +1,425 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ContentTypeJSONAPI is the media type of JSON:API documents.
+const ContentTypeJSONAPI = "application/vnd.api+json"
+
+// JSONAPIDocument is a JSON:API top-level document.
+// It contains the following fields:
+// - Data: the primary data, a Resource or a slice of Resource
+// - Errors: the errors of a failed request, never sent together with Data
+// - Included: the resources related to the primary data, for compound documents
+// - Links: the links of the document, e.g. pagination links
+// - Meta: non-standard information about the document
+type JSONAPIDocument struct {
+	Data     any               `json:"data,omitempty"`
+	Errors   []JSONAPIError    `json:"errors,omitempty"`
+	Included []Resource        `json:"included,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+	Meta     map[string]any    `json:"meta,omitempty"`
+}
+
+// Resource is a JSON:API resource object.
+type Resource struct {
+	Type          string                  `json:"type"`
+	ID            string                  `json:"id,omitempty"`
+	Attributes    map[string]any          `json:"attributes,omitempty"`
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+	Links         map[string]string       `json:"links,omitempty"`
+	Meta          map[string]any          `json:"meta,omitempty"`
+}
+
+// ResourceIdentifier identifies a resource in a relationship.
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Relationship is a JSON:API relationship object. Data is a *ResourceIdentifier, nil for an empty to-one
+// relationship, or a []ResourceIdentifier for a to-many relationship.
+type Relationship struct {
+	Data  any               `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// JSONAPIError is a JSON:API error object.
+type JSONAPIError struct {
+	ID     string              `json:"id,omitempty"`
+	Status string              `json:"status,omitempty"`
+	Code   string              `json:"code,omitempty"`
+	Title  string              `json:"title,omitempty"`
+	Detail string              `json:"detail,omitempty"`
+	Source *JSONAPIErrorSource `json:"source,omitempty"`
+	Meta   map[string]any      `json:"meta,omitempty"`
+}
+
+// JSONAPIErrorSource points to the part of the request that caused a JSON:API error.
+type JSONAPIErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Header    string `json:"header,omitempty"`
+}
+
+// MarshalDocument converts a struct, or a slice of structs, into a JSON:API document.
+// Struct fields are mapped with the "jsonapi" tag:
+// - `jsonapi:"primary,articles"` marks the ID field and sets the resource type; strings, integers and fmt.Stringer are supported
+// - `jsonapi:"attr,title"` adds the field to the attributes, with an optional ",omitempty"
+// - `jsonapi:"relation,author"` adds the field to the relationships, it must be a struct, a pointer to a struct or a slice
+// of them with their own primary field
+// Fields without a "jsonapi" tag are ignored. Related resources are added to the included resources once,
+// which makes the result a compound document.
+//
+// Parameters:
+// - data: The struct, pointer to a struct, or slice of them to convert.
+//
+// Returns:
+// - JSONAPIDocument: The document with the primary data and the included resources.
+// - error: An error if data or a related value does not have a primary field.
+func MarshalDocument(data any) (JSONAPIDocument, error) {
+	m := &resourceMarshaler{seen: map[ResourceIdentifier]bool{}}
+
+	value := reflect.ValueOf(data)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return JSONAPIDocument{}, errors.New("jsonapi: data is nil")
+		}
+		value = value.Elem()
+	}
+
+	var doc JSONAPIDocument
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		resources := make([]Resource, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			resource, err := m.marshal(value.Index(i))
+			if err != nil {
+				return JSONAPIDocument{}, err
+			}
+			m.seen[ResourceIdentifier{Type: resource.Type, ID: resource.ID}] = true
+			resources = append(resources, resource)
+		}
+		doc.Data = resources
+	} else {
+		resource, err := m.marshal(value)
+		if err != nil {
+			return JSONAPIDocument{}, err
+		}
+		m.seen[ResourceIdentifier{Type: resource.Type, ID: resource.ID}] = true
+		doc.Data = resource
+	}
+
+	for len(m.pending) > 0 {
+		related := m.pending[0]
+		m.pending = m.pending[1:]
+		if err := m.include(related); err != nil {
+			return JSONAPIDocument{}, err
+		}
+	}
+	doc.Included = m.included
+	return doc, nil
+}
+
+type resourceMarshaler struct {
+	seen     map[ResourceIdentifier]bool
+	pending  []reflect.Value
+	included []Resource
+}
+
+func (m *resourceMarshaler) marshal(value reflect.Value) (Resource, error) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return Resource{}, errors.New("jsonapi: resource is nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return Resource{}, fmt.Errorf("jsonapi: %s is not a struct", value.Type())
+	}
+
+	var resource Resource
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("jsonapi")
+		if !ok || !field.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		if len(parts) < 2 {
+			return Resource{}, fmt.Errorf("jsonapi: invalid tag %q on %s.%s", tag, typ.Name(), field.Name)
+		}
+		omitEmpty := len(parts) > 2 && parts[2] == "omitempty"
+		fieldValue := value.Field(i)
+
+		switch parts[0] {
+		case "primary":
+			resource.Type = parts[1]
+			resource.ID = resourceID(fieldValue)
+		case "attr":
+			if omitEmpty && isEmpty(fieldValue) {
+				continue
+			}
+			if resource.Attributes == nil {
+				resource.Attributes = map[string]any{}
+			}
+			resource.Attributes[parts[1]] = fieldValue.Interface()
+		case "relation":
+			if omitEmpty && isEmpty(fieldValue) {
+				continue
+			}
+			relationship, err := m.relationship(fieldValue)
+			if err != nil {
+				return Resource{}, err
+			}
+			if resource.Relationships == nil {
+				resource.Relationships = map[string]Relationship{}
+			}
+			resource.Relationships[parts[1]] = relationship
+		default:
+			return Resource{}, fmt.Errorf("jsonapi: invalid tag %q on %s.%s", tag, typ.Name(), field.Name)
+		}
+	}
+
+	if resource.Type == "" {
+		return Resource{}, fmt.Errorf("jsonapi: %s does not have a primary field", typ)
+	}
+	return resource, nil
+}
+
+func (m *resourceMarshaler) relationship(value reflect.Value) (Relationship, error) {
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		identifiers := make([]ResourceIdentifier, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			identifier, err := m.identifier(value.Index(i))
+			if err != nil {
+				return Relationship{}, err
+			}
+			identifiers = append(identifiers, identifier)
+		}
+		return Relationship{Data: identifiers}, nil
+	}
+
+	if (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+		return Relationship{Data: nil}, nil
+	}
+	identifier, err := m.identifier(value)
+	if err != nil {
+		return Relationship{}, err
+	}
+	return Relationship{Data: &identifier}, nil
+}
+
+// identifier returns the identifier of a related value and queues the value to be included.
+func (m *resourceMarshaler) identifier(value reflect.Value) (ResourceIdentifier, error) {
+	identifier, err := identify(value)
+	if err != nil {
+		return ResourceIdentifier{}, err
+	}
+
+	m.pending = append(m.pending, value)
+	return identifier, nil
+}
+
+// identify returns the type and ID of a value from its primary field.
+func identify(value reflect.Value) (ResourceIdentifier, error) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return ResourceIdentifier{}, errors.New("jsonapi: related resource is nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return ResourceIdentifier{}, fmt.Errorf("jsonapi: %s is not a struct", value.Type())
+	}
+
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		kind, resourceType, _ := strings.Cut(typ.Field(i).Tag.Get("jsonapi"), ",")
+		if kind == "primary" {
+			resourceType, _, _ = strings.Cut(resourceType, ",")
+			return ResourceIdentifier{Type: resourceType, ID: resourceID(value.Field(i))}, nil
+		}
+	}
+	return ResourceIdentifier{}, fmt.Errorf("jsonapi: %s does not have a primary field", typ)
+}
+
+// include adds a related value to the included resources, unless it is already part of the document
+// or only has an identifier.
+func (m *resourceMarshaler) include(value reflect.Value) error {
+	identifier, err := identify(value)
+	if err != nil {
+		return err
+	}
+	if m.seen[identifier] {
+		return nil
+	}
+	m.seen[identifier] = true
+
+	resource, err := m.marshal(value)
+	if err != nil {
+		return err
+	}
+	if resource.Attributes == nil && resource.Relationships == nil {
+		return nil
+	}
+	m.included = append(m.included, resource)
+	return nil
+}
+
+func resourceID(value reflect.Value) string {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() == 0 {
+			return ""
+		}
+		return strconv.FormatUint(value.Uint(), 10)
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// jsonAPIDocument converts a response envelope into a JSON:API document.
+func jsonAPIDocument(r *http.Request, status int, env envelope) (JSONAPIDocument, error) {
+	envelopeStatus, data, message, page := env.fields()
+
+	switch envelopeStatus {
+	case StatusFailed:
+		return JSONAPIDocument{Errors: jsonAPIErrors(r, status, data)}, nil
+	case StatusError:
+		return JSONAPIDocument{Errors: []JSONAPIError{{
+			Status: strconv.Itoa(status),
+			Title:  http.StatusText(status),
+			Detail: message,
+		}}}, nil
+	}
+
+	var doc JSONAPIDocument
+	switch value := data.(type) {
+	case nil:
+		doc.Meta = map[string]any{"status": StatusSuccess}
+	case JSONAPIDocument:
+		doc = value
+	default:
+		if reflect.ValueOf(data).Kind() == reflect.Map && reflect.ValueOf(data).IsNil() {
+			doc.Meta = map[string]any{"status": StatusSuccess}
+			break
+		}
+		if !isResourceType(reflect.TypeOf(data)) {
+			doc.Meta = map[string]any{"status": StatusSuccess, "data": data}
+			break
+		}
+
+		var err error
+		if doc, err = MarshalDocument(data); err != nil {
+			return JSONAPIDocument{}, err
+		}
+	}
+
+	if page != nil {
+		if doc.Links == nil {
+			doc.Links = map[string]string{}
+		}
+		for rel, target := range page.links(*r.URL) {
+			doc.Links[rel] = target
+		}
+		if doc.Meta == nil {
+			doc.Meta = map[string]any{}
+		}
+		doc.Meta["pagination"] = page
+	}
+	return doc, nil
+}
+
+// isResourceType reports whether values of a type can be marshaled into resources, see MarshalDocument.
+func isResourceType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// jsonAPIErrors converts the data of a failed response into JSON:API error objects.
+// Entries with a "field" member point to the attribute in the request body, or to the query parameter
+// for requests without a body, and their "rule" member becomes the error code.
+func jsonAPIErrors(r *http.Request, status int, data any) []JSONAPIError {
+	var entries []map[string]any
+	if b, err := json.Marshal(data); err == nil {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&entries); err != nil {
+			entries = nil
+		}
+	}
+	if len(entries) == 0 {
+		return []JSONAPIError{{Status: strconv.Itoa(status), Title: http.StatusText(status)}}
+	}
+
+	errs := make([]JSONAPIError, 0, len(entries))
+	for _, entry := range entries {
+		apiError := JSONAPIError{Status: strconv.Itoa(status), Title: http.StatusText(status)}
+		if message, ok := entry["message"].(string); ok {
+			apiError.Detail = message
+		}
+		if rule, ok := entry["rule"].(string); ok {
+			apiError.Code = rule
+		}
+		if field, ok := entry["field"].(string); ok && field != "" {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete {
+				apiError.Source = &JSONAPIErrorSource{Parameter: field}
+			} else {
+				apiError.Source = &JSONAPIErrorSource{Pointer: jsonPointer(field)}
+			}
+		}
+		errs = append(errs, apiError)
+	}
+	return errs
+}
+
+// jsonPointer converts a field path such as "items[0].name" into a JSON pointer to the attribute.
+func jsonPointer(field string) string {
+	var b strings.Builder
+	b.WriteString("/data/attributes")
+	for _, part := range strings.Split(strings.ReplaceAll(field, "[", ".["), ".") {
+		if part == "" {
+			continue
+		}
+		part = strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~", "~0"), "/", "~1")
+		b.WriteString("/")
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,285 @@
+package json
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Query parameters used for pagination.
+const (
+	PageParam   = "page"
+	LimitParam  = "limit"
+	CursorParam = "cursor"
+)
+
+const maxCursorLength = 1024
+
+// Page is the paging metadata included in the envelope of list responses.
+// It is implemented by OffsetPagination and CursorPagination.
+type Page interface {
+	links(u url.URL) map[string]string
+}
+
+// OffsetPagination is the paging metadata of page/limit based lists.
+// It contains the following fields:
+// - Page: the current page, starting from 1
+// - Limit: the maximum number of items in a page
+// - Total: the total number of items in the list
+// - TotalPages: the total number of pages in the list
+type OffsetPagination struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"totalPages"`
+}
+
+// NewOffsetPagination creates the paging metadata for the requested page and the total number of items.
+//
+// Parameters:
+// - params: The PageParams parsed from the request.
+// - total: The total number of items in the list.
+//
+// Returns:
+// - OffsetPagination: The paging metadata with the total number of pages computed from the limit.
+func NewOffsetPagination(params PageParams, total int64) OffsetPagination {
+	totalPages := 0
+	if params.Limit > 0 {
+		totalPages = int((total + int64(params.Limit) - 1) / int64(params.Limit))
+	}
+
+	return OffsetPagination{
+		Page:       params.Page,
+		Limit:      params.Limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+}
+
+func (p OffsetPagination) links(u url.URL) map[string]string {
+	link := func(page int) string {
+		query := u.Query()
+		query.Del(CursorParam)
+		query.Set(PageParam, strconv.Itoa(page))
+		query.Set(LimitParam, strconv.Itoa(p.Limit))
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{"first": link(1)}
+	if p.TotalPages > 0 {
+		links["last"] = link(p.TotalPages)
+	}
+	if p.Page > 1 {
+		links["prev"] = link(min(p.Page-1, max(p.TotalPages, 1)))
+	}
+	if p.Page < p.TotalPages {
+		links["next"] = link(p.Page + 1)
+	}
+	return links
+}
+
+// CursorPagination is the paging metadata of cursor based lists.
+// It contains the following fields:
+// - Limit: the maximum number of items in a page
+// - Next: the opaque cursor of the next page, empty when there is no next page
+// - Prev: the opaque cursor of the previous page, empty when there is no previous page
+type CursorPagination struct {
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
+func (p CursorPagination) links(u url.URL) map[string]string {
+	link := func(cursor string) string {
+		query := u.Query()
+		query.Del(PageParam)
+		query.Del(CursorParam)
+		if cursor != "" {
+			query.Set(CursorParam, cursor)
+		}
+		query.Set(LimitParam, strconv.Itoa(p.Limit))
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+
+	links := map[string]string{"first": link("")}
+	if p.Prev != "" {
+		links["prev"] = link(p.Prev)
+	}
+	if p.Next != "" {
+		links["next"] = link(p.Next)
+	}
+	return links
+}
+
+// PageConfig holds the limits applied when parsing pagination query parameters.
+// It contains the following fields:
+// - DefaultLimit: the limit used when the request does not have one, 20 when zero
+// - MaxLimit: the largest limit a request may ask for, 100 when zero
+type PageConfig struct {
+	DefaultLimit, MaxLimit int
+}
+
+// PageParams is the pagination requested by the client.
+// It contains the following fields:
+// - Page: the requested page, starting from 1
+// - Limit: the requested number of items in a page
+// - Offset: the number of items to skip, derived from Page and Limit
+// - Cursor: the opaque cursor of the requested page, empty for the first page or offset pagination
+type PageParams struct {
+	Page, Limit, Offset int
+	Cursor              string
+}
+
+// ParsePageParams parses the page, limit and cursor query parameters of the request.
+// If a parameter is invalid, it sends a failed response with a status of http.StatusBadRequest
+// describing every invalid parameter, and returns false. The handler must return without writing anything else.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the failed response to.
+// - r: The http.Request to read the query parameters from.
+// - config: The default and maximum limit.
+//
+// Returns:
+// - PageParams: The requested pagination.
+// - bool: Whether the query parameters are valid.
+func ParsePageParams(w http.ResponseWriter, r *http.Request, config PageConfig) (PageParams, bool) {
+	if config.DefaultLimit <= 0 {
+		config.DefaultLimit = 20
+	}
+	if config.MaxLimit <= 0 {
+		config.MaxLimit = 100
+	}
+
+	query := r.URL.Query()
+	params := PageParams{Page: 1, Limit: min(config.DefaultLimit, config.MaxLimit)}
+	var messages []map[string]any
+
+	if value := query.Get(PageParam); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			messages = append(messages, map[string]any{
+				"field":   PageParam,
+				"message": "page must be a positive integer",
+			})
+		}
+		params.Page = page
+	}
+
+	if value := query.Get(LimitParam); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > config.MaxLimit {
+			messages = append(messages, map[string]any{
+				"field":   LimitParam,
+				"message": fmt.Sprintf("limit must be an integer between 1 and %d", config.MaxLimit),
+			})
+		}
+		params.Limit = limit
+	}
+
+	// The offset of the page, and of the item after it, must not overflow.
+	if params.Page > 1 && params.Limit > 0 && params.Page > math.MaxInt/params.Limit {
+		messages = append(messages, map[string]any{
+			"field":   PageParam,
+			"message": "page is too large",
+		})
+	}
+
+	if value := query.Get(CursorParam); value != "" {
+		switch {
+		case len(value) > maxCursorLength:
+			messages = append(messages, map[string]any{
+				"field":   CursorParam,
+				"message": "cursor is too long",
+			})
+		case query.Has(PageParam):
+			messages = append(messages, map[string]any{
+				"field":   CursorParam,
+				"message": "cursor cannot be combined with page",
+			})
+		}
+		params.Cursor = value
+	}
+
+	if len(messages) > 0 {
+		FailedResponse(w, r, http.StatusBadRequest, messages)
+		return PageParams{}, false
+	}
+
+	params.Offset = (params.Page - 1) * params.Limit
+	return params, true
+}
+
+// EncodeCursor encodes a value into an opaque URL-safe cursor.
+//
+// Parameters:
+// - v: The value identifying the position in the list, e.g. the sort key of the last item.
+//
+// Returns:
+// - string: The opaque cursor.
+// - error: An error if the value cannot be encoded.
+func EncodeCursor(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor decodes an opaque cursor created by EncodeCursor into v.
+//
+// Parameters:
+// - cursor: The opaque cursor sent by the client.
+// - v: A pointer to the value the cursor is decoded into.
+//
+// Returns:
+// - error: An error if the cursor is malformed.
+func DecodeCursor(cursor string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// PaginatedResponseOf sends a successful JSON response with a page of a list.
+// It sets the request ID header, service name, and service version in the response headers,
+// and RFC 8288 Link headers to the first, last, previous and next pages built from the request URL.
+// Then it sets the status of the response and sends an Envelope with a status of "success", the data and the paging metadata.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - status: The HTTP status code to set in the response.
+// - data: The items of the page.
+// - page: The paging metadata, either OffsetPagination or CursorPagination.
+func PaginatedResponseOf[T any](w http.ResponseWriter, r *http.Request, status int, data T, page Page) {
+	Default().paginated(w, r, status, Envelope[T]{Status: StatusSuccess, Data: data, Pagination: page}, page)
+}
+
+// Paginated sends a successful response with a page of a list. See PaginatedResponseOf.
+func (rs *Responder) Paginated(w http.ResponseWriter, r *http.Request, status int, data any, page Page) {
+	rs.paginated(w, r, status, Envelope[any]{Status: StatusSuccess, Data: data, Pagination: page}, page)
+}
+
+func (rs *Responder) paginated(w http.ResponseWriter, r *http.Request, status int, envelope any, page Page) {
+	rs.setHeaders(w, r)
+	if page != nil {
+		setLinkHeaders(w, page.links(*r.URL))
+	}
+
+	rs.write(w, r, status, envelope)
+}
+
+func setLinkHeaders(w http.ResponseWriter, links map[string]string) {
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if target, ok := links[rel]; ok {
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=%q", target, rel))
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// ContentTypeProblemJSON is the media type of problem details documents as defined in RFC 9457.
+const ContentTypeProblemJSON = "application/problem+json"
+
+// ErrorFormat selects the document format written by ErrorResponse and FailedResponse.
+type ErrorFormat int
+
+const (
+	// FormatJSend writes the {"status": "error"|"failed", ...} envelope. This is the default.
+	FormatJSend ErrorFormat = iota
+	// FormatProblem writes RFC 7807/9457 problem details documents with the application/problem+json media type.
+	FormatProblem
+)
+
+// SetErrorFormat changes the document format written by ErrorResponse and FailedResponse.
+// It replaces the default Responder with a copy using the format, and is meant to be called once when the service starts.
+//
+// Parameters:
+// - format: The ErrorFormat used for every subsequent error and failed response.
+func SetErrorFormat(format ErrorFormat) {
+	responder := *Default()
+	responder.ErrorFormat = format
+	SetDefault(&responder)
+}
+
+// Problem is a problem details document as defined in RFC 9457 (which obsoletes RFC 7807).
+// It contains the following fields:
+// - Type: a URI reference that identifies the problem type, "about:blank" when empty
+// - Title: a short summary of the problem type, the status text when empty
+// - Status: the HTTP status code of the response
+// - Detail: an explanation specific to this occurrence of the problem
+// - Instance: a URI reference that identifies this occurrence of the problem, the request path when empty
+// - Extensions: additional members serialized at the top level of the document
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// MarshalJSON encodes the problem with its extension members flattened into the document.
+// Extension members never override the standard members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.document())
+}
+
+func (p Problem) document() problemDocument {
+	doc := make(problemDocument, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		switch key {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		doc[key] = value
+	}
+
+	doc["type"] = p.Type
+	if p.Title != "" {
+		doc["title"] = p.Title
+	}
+	if p.Status != 0 {
+		doc["status"] = p.Status
+	}
+	if p.Detail != "" {
+		doc["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		doc["instance"] = p.Instance
+	}
+
+	return doc
+}
+
+// Error returns the title and detail of the problem, so a Problem can be used as an error value.
+func (p Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return fmt.Sprintf("%s: %s", p.Title, p.Detail)
+}
+
+// ProblemResponse sends a problem details document.
+// It sets the request ID header, service name, and service version in the response headers.
+// Missing type, title and instance members are filled from the status and the request, and the request ID,
+// service name and service version are added as extension members.
+// It is a wrapper of Default().Problem.
+//
+// Parameters:
+// - w: The http.ResponseWriter to write the response to.
+// - r: The http.Request that we are responding to.
+// - problem: The problem details to send, its Status is used as the HTTP status code.
+func ProblemResponse(w http.ResponseWriter, r *http.Request, problem Problem) {
+	Default().Problem(w, r, problem)
+}
+
+// Problem sends a problem details document. See ProblemResponse.
+func (rs *Responder) Problem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	rs.setHeaders(w, r)
+	rs.writeProblem(w, r, problem)
+}
+
+func (rs *Responder) writeProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	extensions := make(map[string]any, len(problem.Extensions)+3)
+	for key, value := range problem.Extensions {
+		extensions[key] = value
+	}
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		extensions[web.RequestID] = id
+	}
+	info := rs.service(r)
+	if info.Name != "" {
+		extensions[web.ServiceName] = info.Name
+	}
+	if info.Version != "" {
+		extensions[web.ServiceVersion] = info.Version
+	}
+	problem.Extensions = extensions
+
+	rs.write(w, r, problem.Status, problem)
+}
@@ -0,0 +1,290 @@
+package json
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dynastymasra/go-library/web"
+)
+
+// Style selects the structure of the documents written by a Responder.
+type Style int
+
+const (
+	// StyleJSend writes the {"status": ..., "data": ...} envelope. This is the default.
+	StyleJSend Style = iota
+	// StyleJSONAPI writes JSON:API documents with the application/vnd.api+json media type, see MarshalDocument.
+	// Data that is not a struct or a slice of structs, e.g. a map, is written in the meta member.
+	StyleJSONAPI
+	// StyleHAL writes HAL resources with the application/hal+json media type, see HALResource.
+	StyleHAL
+)
+
+// HeaderPolicy selects the headers a Responder sets on every response.
+// It contains the following fields:
+// - DisableRequestID: whether the request ID header is omitted
+// - DisableService: whether the service name and service version headers are omitted
+// - DisableTrace: whether the trace ID and span ID headers are omitted
+// - Headers: additional headers set on every response
+type HeaderPolicy struct {
+	DisableRequestID bool
+	DisableService   bool
+	DisableTrace     bool
+	Headers          http.Header
+}
+
+// ServiceInfo is the service metadata sent with responses.
+// It contains the following fields:
+// - Name: the name of the service
+// - Version: the version of the service
+type ServiceInfo struct {
+	Name, Version string
+}
+
+// Responder writes the responses of the package. It is configured once, usually when the service starts,
+// and is safe for concurrent use as long as it is not modified afterwards. The zero value writes JSend-like
+// envelopes with negotiated encoders, which is what the package-level functions do by default.
+// When the Accept header allows none of the encoders, successful responses become http.StatusNotAcceptable,
+// while error and failed responses keep their status and are sent as JSON.
+// It contains the following fields:
+// - Style: the structure of the documents
+// - ErrorFormat: the document format of error and failed responses, FormatProblem takes precedence over Style
+// - Encoder: the encoder used for every response instead of negotiating one from the Accept header, not used when nil
+// - MediaType: the Content-Type of the responses written with Encoder, "application/json" when empty
+// - Headers: the headers set on every response
+// - Service: the service metadata used when the request context has none, see middleware.Service
+// - BeforeWrite: called with the document before it is encoded, its result is encoded instead, not called when nil
+// - AfterWrite: called after the response is written with the number of body bytes written, not called when nil
+// - Deprecation: the lifecycle of a deprecated route, see Deprecated, not used when nil
+type Responder struct {
+	Style       Style
+	ErrorFormat ErrorFormat
+	Encoder     Encoder
+	MediaType   string
+	Headers     HeaderPolicy
+	Service     ServiceInfo
+	BeforeWrite func(w http.ResponseWriter, r *http.Request, status int, v any) any
+	AfterWrite  func(r *http.Request, status int, written int, err error)
+	Deprecation *Deprecation
+}
+
+var defaultResponder atomic.Pointer[Responder]
+
+func init() {
+	defaultResponder.Store(&Responder{})
+}
+
+// Default returns the Responder used by the package-level functions.
+func Default() *Responder {
+	return defaultResponder.Load()
+}
+
+// SetDefault replaces the Responder used by the package-level functions.
+// It is meant to be called once when the service starts, and the Responder must not be modified afterwards.
+//
+// Parameters:
+// - responder: The Responder to use, a nil Responder restores the zero value.
+func SetDefault(responder *Responder) {
+	if responder == nil {
+		responder = &Responder{}
+	}
+	defaultResponder.Store(responder)
+}
+
+// Success sends a successful response without any additional data. See SuccessResponse.
+func (rs *Responder) Success(w http.ResponseWriter, r *http.Request, status int) {
+	rs.setHeaders(w, r)
+	rs.write(w, r, status, Envelope[any]{Status: StatusSuccess})
+}
+
+// Data sends a successful response with data. See DataResponseOf.
+func (rs *Responder) Data(w http.ResponseWriter, r *http.Request, status int, data any) {
+	rs.setHeaders(w, r)
+	rs.write(w, r, status, Envelope[any]{Status: StatusSuccess, Data: data})
+}
+
+// Failed sends a failed response with data. See FailedResponseOf.
+func (rs *Responder) Failed(w http.ResponseWriter, r *http.Request, status int, data any) {
+	rs.failed(w, r, status, data, Envelope[any]{Status: StatusFailed, Data: data})
+}
+
+// Error sends an error response with a message. See ErrorResponse.
+func (rs *Responder) Error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	rs.setHeaders(w, r)
+
+	if rs.ErrorFormat == FormatProblem {
+		rs.writeProblem(w, r, Problem{Status: status, Detail: message})
+		return
+	}
+	rs.write(w, r, status, Envelope[any]{Status: StatusError, Message: message})
+}
+
+// failed sends the envelope of a failed response, or the data as a problem details document.
+func (rs *Responder) failed(w http.ResponseWriter, r *http.Request, status int, data, doc any) {
+	rs.setHeaders(w, r)
+
+	if rs.ErrorFormat == FormatProblem {
+		rs.writeProblem(w, r, Problem{
+			Status:     status,
+			Extensions: map[string]any{"errors": data},
+		})
+		return
+	}
+	rs.write(w, r, status, doc)
+}
+
+// service returns the service metadata of the request, falling back to the configured metadata.
+func (rs *Responder) service(r *http.Request) ServiceInfo {
+	info := rs.Service
+	if name, ok := r.Context().Value(web.ServiceName).(string); ok && name != "" {
+		info.Name = name
+	}
+	if version, ok := r.Context().Value(web.ServiceVersion).(string); ok && version != "" {
+		info.Version = version
+	}
+	return info
+}
+
+// setHeaders sets the request ID, trace, service name and service version headers shared by all responses,
+// and the deprecation headers of deprecated routes. Headers without a value are not set.
+func (rs *Responder) setHeaders(w http.ResponseWriter, r *http.Request) {
+	if rs.Deprecation != nil {
+		rs.Deprecation.setHeaders(w)
+		rs.Deprecation.log(r)
+	}
+
+	for key, values := range rs.Headers.Headers {
+		w.Header()[key] = append([]string(nil), values...)
+	}
+
+	if !rs.Headers.DisableRequestID {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+	}
+
+	if !rs.Headers.DisableTrace {
+		if tc, ok := web.TraceFromContext(r.Context()); ok {
+			w.Header().Set(web.XTraceID, tc.TraceID)
+			w.Header().Set(web.XSpanID, tc.SpanID)
+		}
+	}
+
+	if !rs.Headers.DisableService {
+		info := rs.service(r)
+		if info.Name != "" {
+			w.Header().Set(web.XServiceName, info.Name)
+		}
+		if info.Version != "" {
+			w.Header().Set(web.XServiceVersion, info.Version)
+		}
+	}
+}
+
+// write encodes v and sends it with the given status.
+func (rs *Responder) write(w http.ResponseWriter, r *http.Request, status int, v any) {
+	mediaType, body, ok := rs.encode(w, r, status, v)
+	if !ok {
+		return
+	}
+	rs.send(w, r, status, mediaType, body)
+}
+
+// encode encodes v with the configured encoder, or the encoder negotiated for the request.
+// When v cannot be encoded, it sends the failure response itself and returns false.
+func (rs *Responder) encode(w http.ResponseWriter, r *http.Request, status int, v any) (string, []byte, bool) {
+	if d := rs.Deprecation; d != nil && status < http.StatusBadRequest && d.gone(time.Now()) {
+		retired := *rs
+		retired.Deprecation = nil
+		retired.Failed(w, r, http.StatusGone, d.goneMessages())
+		return "", nil, false
+	}
+	if env, ok := v.(envelope); ok {
+		switch rs.Style {
+		case StyleJSONAPI:
+			doc, err := jsonAPIDocument(r, status, env)
+			if err != nil {
+				log.Error().Err(err).Str(web.RequestID, middleware.GetReqID(r.Context())).
+					Str("method", r.Method).Str("path", r.URL.Path).Msg("Failed to marshal JSON:API document")
+				rs.Error(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return "", nil, false
+			}
+			v = doc
+		case StyleHAL:
+			v = halDocument(r, env)
+		}
+	}
+	if rs.BeforeWrite != nil {
+		v = rs.BeforeWrite(w, r, status, v)
+	}
+
+	jsonType, xmlType := ContentTypeJSON, ContentTypeXML
+	switch value := v.(type) {
+	case Problem:
+		jsonType, xmlType = ContentTypeProblemJSON, ContentTypeProblemXML
+		v = value.document()
+	case JSONAPIDocument:
+		jsonType = ContentTypeJSONAPI
+	case HALResource:
+		jsonType = ContentTypeHAL
+	}
+
+	mediaType, encoder := rs.MediaType, rs.Encoder
+	if encoder == nil {
+		web.AddVary(w.Header(), "Accept")
+
+		var ok bool
+		mediaType, encoder, ok = negotiate(r, jsonType, xmlType)
+		if !ok {
+			if status < http.StatusBadRequest {
+				rs.send(w, r, http.StatusNotAcceptable, ContentTypeJSON, notAcceptable())
+				return "", nil, false
+			}
+			// Errors keep their status in JSON instead, RFC 9110 allows ignoring the Accept header.
+			mediaType, encoder = jsonType, jsonEncoder()
+		}
+	} else if mediaType == "" {
+		mediaType = jsonType
+	}
+
+	buf := &bytes.Buffer{}
+	if err := encoder.Encode(buf, v); err != nil {
+		log.Error().Err(err).Str(web.RequestID, middleware.GetReqID(r.Context())).
+			Str("method", r.Method).Str("path", r.URL.Path).Str("mediaType", mediaType).Msg("Failed to encode response")
+		text := http.StatusText(http.StatusInternalServerError) + "\n"
+		rs.send(w, r, http.StatusInternalServerError, "text/plain; charset=utf-8", []byte(text))
+		return "", nil, false
+	}
+
+	if strings.HasSuffix(mediaType, "xml") {
+		mediaType += "; charset=utf-8"
+	}
+	return mediaType, buf.Bytes(), true
+}
+
+// send writes the status and the body, and calls AfterWrite. Responses without a body, such as
+// http.StatusNotModified, have no media type.
+func (rs *Responder) send(w http.ResponseWriter, r *http.Request, status int, mediaType string, body []byte) {
+	if mediaType != "" {
+		w.Header().Set("Content-Type", mediaType)
+	}
+	w.WriteHeader(status)
+
+	var (
+		n   int
+		err error
+	)
+	if len(body) > 0 {
+		n, err = w.Write(body)
+	}
+
+	if rs.AfterWrite != nil {
+		rs.AfterWrite(r, status, n, err)
+	}
+}
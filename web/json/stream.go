@@ -0,0 +1,265 @@
+package json
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Media types of the streaming writers.
+const (
+	ContentTypeNDJSON      = "application/x-ndjson"
+	ContentTypeEventStream = "text/event-stream"
+)
+
+// ErrStreamClosed is returned when writing to a stream that has been closed.
+var ErrStreamClosed = errors.New("stream is closed")
+
+// NDJSONWriter streams newline delimited JSON records, flushing every record to the client.
+type NDJSONWriter struct {
+	mu  sync.Mutex
+	ctx context.Context
+	rc  *http.ResponseController
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter starts a newline delimited JSON response.
+// It sets the request ID header, service name, and service version in the response headers,
+// then sends the status and the headers immediately so the client can start reading records.
+//
+// Parameters:
+// - w: The http.ResponseWriter to stream the records to.
+// - r: The http.Request that we are responding to. Writes fail once its context is done.
+// - status: The HTTP status code to set in the response.
+//
+// Returns:
+// - *NDJSONWriter: The writer used to send records.
+func NewNDJSONWriter(w http.ResponseWriter, r *http.Request, status int) *NDJSONWriter {
+	Default().setHeaders(w, r)
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(status)
+
+	rc := http.NewResponseController(w)
+	rc.Flush() //nolint:errcheck
+
+	return &NDJSONWriter{ctx: r.Context(), rc: rc, enc: json.NewEncoder(w)}
+}
+
+// Write encodes a record on its own line and flushes it to the client.
+//
+// Parameters:
+// - v: The record to send.
+//
+// Returns:
+// - error: The context error if the client has disconnected, or an error if the record cannot be written.
+func (n *NDJSONWriter) Write(v any) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.ctx.Err(); err != nil {
+		return err
+	}
+	if err := n.enc.Encode(v); err != nil {
+		return err
+	}
+	return flush(n.rc)
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (n *NDJSONWriter) Done() <-chan struct{} {
+	return n.ctx.Done()
+}
+
+// Event is a Server-Sent Event.
+// It contains the following fields:
+// - ID: the event ID the client sends back in the Last-Event-ID header when it reconnects
+// - Event: the event type, "message" on the client when empty
+// - Data: the payload, strings are sent as is and other values are encoded as JSON
+// - Retry: the reconnection delay hint for the client, not sent when zero
+type Event struct {
+	ID    string
+	Event string
+	Data  any
+	Retry time.Duration
+}
+
+// SSEWriter streams Server-Sent Events, flushing every event to the client.
+type SSEWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	ctx    context.Context
+	rc     *http.ResponseController
+	closed bool
+	stop   chan struct{}
+}
+
+// NewSSEWriter starts a Server-Sent Events response.
+// It sets the request ID header, service name, and service version in the response headers,
+// then sends a status of http.StatusOK and the headers immediately so the client can start reading events.
+// The writer must be closed with Close before the handler returns.
+//
+// Parameters:
+// - w: The http.ResponseWriter to stream the events to.
+// - r: The http.Request that we are responding to. Writes fail once its context is done.
+//
+// Returns:
+// - *SSEWriter: The writer used to send events.
+func NewSSEWriter(w http.ResponseWriter, r *http.Request) *SSEWriter {
+	Default().setHeaders(w, r)
+	w.Header().Set("Content-Type", ContentTypeEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "keep-alive")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	rc.Flush() //nolint:errcheck
+
+	return &SSEWriter{w: w, ctx: r.Context(), rc: rc, stop: make(chan struct{})}
+}
+
+// LastEventID returns the ID of the last event received by a reconnecting client, or an empty string.
+//
+// Parameters:
+// - r: The http.Request of the stream.
+//
+// Returns:
+// - string: The value of the Last-Event-ID header.
+func LastEventID(r *http.Request) string {
+	return r.Header.Get("Last-Event-ID")
+}
+
+// Send writes an event and flushes it to the client.
+//
+// Parameters:
+// - event: The Event to send.
+//
+// Returns:
+// - error: The context error if the client has disconnected, ErrStreamClosed if the writer is closed,
+// or an error if the event cannot be encoded or written.
+func (s *SSEWriter) Send(event Event) error {
+	if strings.ContainsAny(event.ID, "\r\n\x00") || strings.ContainsAny(event.Event, "\r\n\x00") {
+		return errors.New("event id and type must not contain line breaks")
+	}
+
+	var data string
+	switch value := event.Data.(type) {
+	case nil:
+	case string:
+		data = value
+	case []byte:
+		data = string(value)
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+
+	buf := &bytes.Buffer{}
+	if event.ID != "" {
+		fmt.Fprintf(buf, "id: %s\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(buf, "event: %s\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(buf, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	// CRLF, CR and LF all end a line in an event stream, so each is written as a separate data field.
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+
+	return s.write(buf.Bytes())
+}
+
+// Retry tells the client how long to wait before reconnecting when the stream is interrupted.
+//
+// Parameters:
+// - delay: The reconnection delay.
+//
+// Returns:
+// - error: An error if the hint cannot be written.
+func (s *SSEWriter) Retry(delay time.Duration) error {
+	return s.write([]byte(fmt.Sprintf("retry: %d\n\n", delay.Milliseconds())))
+}
+
+// Heartbeat sends a comment every interval until the client disconnects or the writer is closed,
+// which keeps proxies from closing idle streams and detects disconnected clients early.
+//
+// Parameters:
+// - interval: The time between heartbeats.
+func (s *SSEWriter) Heartbeat(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.write([]byte(": heartbeat\n\n")); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Done returns a channel that is closed when the client disconnects.
+func (s *SSEWriter) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Close stops the heartbeats and rejects further events. It is safe to call more than once.
+func (s *SSEWriter) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+func (s *SSEWriter) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+	return flush(s.rc)
+}
+
+// flush sends buffered data to the client, ignoring writers that cannot flush.
+func flush(rc *http.ResponseController) error {
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
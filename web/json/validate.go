@@ -0,0 +1,266 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FieldError describes a field of a request that does not satisfy a validation rule.
+// It contains the following fields:
+// - Field: the path of the field using the JSON names, e.g. "items[0].name"
+// - Rule: the rule that failed, e.g. "required" or "max"
+// - Message: a human readable description of the failure
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is the list of fields that failed validation.
+type ValidationErrors []FieldError
+
+// Error returns the messages of all field errors.
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// rule is a parsed rule of a "validate" tag.
+type rule struct {
+	name, param string
+	limit       float64
+	pattern     *regexp.Regexp
+}
+
+// parsedRules caches the rules of every "validate" tag by its value.
+var parsedRules sync.Map
+
+// Validate checks the struct tag rules of v and its nested structs, slices and maps.
+// Rules are read from the "validate" tag as a comma separated list:
+// - omitempty: skip the other rules when the field has its zero value
+// - required: the field must not have its zero value, and slices and maps must not be empty
+// - min=N, max=N: the bounds of numbers, or the length of strings (in characters), slices and maps
+// - enum=a|b|c: the field must be one of the listed values
+// - regex=PATTERN: strings must match the pattern, it must be the last rule because the pattern may contain commas
+// Nested structs are validated when the field has no "validate" tag, or a tag of "-" to skip them.
+// Unknown rules, such as the rules of other validation packages, and rules with an invalid parameter are logged
+// once and ignored.
+//
+// Parameters:
+// - v: The struct, or pointer to a struct, to validate.
+//
+// Returns:
+// - error: ValidationErrors with one entry per invalid field, or nil if v is valid.
+func Validate(v any) error {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateValue(value reflect.Value, path string, errs *ValidationErrors) {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		typ := value.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag := field.Tag.Get("validate")
+			if tag == "-" {
+				continue
+			}
+
+			fieldValue := value.Field(i)
+			fieldPath := path
+			if !field.Anonymous {
+				fieldPath = joinPath(path, jsonName(field))
+			}
+
+			if tag != "" && !validateRules(fieldValue, fieldPath, tag, errs) {
+				continue
+			}
+			validateValue(fieldValue, fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())), errs)
+		}
+	}
+}
+
+// validateRules applies the rules of a tag to a field and reports whether the field is valid.
+func validateRules(value reflect.Value, path, tag string, errs *ValidationErrors) bool {
+	indirect := value
+	for indirect.Kind() == reflect.Pointer || indirect.Kind() == reflect.Interface {
+		if indirect.IsNil() {
+			break
+		}
+		indirect = indirect.Elem()
+	}
+
+	for _, r := range parseRules(tag) {
+		if r.name == "omitempty" {
+			if isEmpty(indirect) {
+				return true
+			}
+			continue
+		}
+		if r.name == "required" {
+			if isEmpty(indirect) {
+				*errs = append(*errs, FieldError{Field: path, Rule: r.name, Message: fmt.Sprintf("%s is required", path)})
+				return false
+			}
+			continue
+		}
+		if (indirect.Kind() == reflect.Pointer || indirect.Kind() == reflect.Interface) && indirect.IsNil() {
+			continue
+		}
+
+		if message, ok := checkRule(indirect, r); !ok {
+			*errs = append(*errs, FieldError{Field: path, Rule: r.name, Message: fmt.Sprintf("%s %s", path, message)})
+			return false
+		}
+	}
+	return true
+}
+
+// parseRules parses the rules of a tag once, logging and dropping the rules that cannot be applied.
+func parseRules(tag string) []rule {
+	if cached, ok := parsedRules.Load(tag); ok {
+		return cached.([]rule)
+	}
+
+	var rules []rule
+	for remaining := tag; remaining != ""; {
+		var text string
+		if strings.HasPrefix(strings.TrimSpace(remaining), "regex=") {
+			text, remaining = remaining, ""
+		} else {
+			text, remaining, _ = strings.Cut(remaining, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(text), "=")
+		r := rule{name: name, param: param}
+
+		var err error
+		switch name {
+		case "omitempty", "required", "enum":
+		case "min", "max":
+			r.limit, err = strconv.ParseFloat(param, 64)
+		case "regex":
+			r.pattern, err = regexp.Compile(param)
+		default:
+			err = fmt.Errorf("unknown rule")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("tag", tag).Str("rule", name).Msg("Ignoring validation rule")
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	cached, _ := parsedRules.LoadOrStore(tag, rules)
+	return cached.([]rule)
+}
+
+func checkRule(value reflect.Value, r rule) (string, bool) {
+	switch r.name {
+	case "min", "max":
+		size, isLength := measure(value)
+		if r.name == "min" && size < r.limit {
+			if isLength {
+				return fmt.Sprintf("must have a length of at least %s", r.param), false
+			}
+			return fmt.Sprintf("must be at least %s", r.param), false
+		}
+		if r.name == "max" && size > r.limit {
+			if isLength {
+				return fmt.Sprintf("must have a length of at most %s", r.param), false
+			}
+			return fmt.Sprintf("must be at most %s", r.param), false
+		}
+	case "enum":
+		actual := fmt.Sprint(value.Interface())
+		for _, allowed := range strings.Split(r.param, "|") {
+			if actual == allowed {
+				return "", true
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.ReplaceAll(r.param, "|", ", ")), false
+	case "regex":
+		if value.Kind() == reflect.String && !r.pattern.MatchString(value.String()) {
+			return "has an invalid format", false
+		}
+	}
+	return "", true
+}
+
+// measure returns the value of numbers or the length of strings, slices and maps.
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	default:
+		return 0, false
+	}
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// jsonName returns the name of a struct field in JSON documents.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
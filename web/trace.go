@@ -0,0 +1,174 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C Trace Context.
+const (
+	Traceparent = "traceparent"
+	Tracestate  = "tracestate"
+)
+
+// TraceContext is the W3C Trace Context of a request.
+// It contains the following fields:
+// - TraceID: the 32 lower-case hex characters that identify the trace
+// - SpanID: the 16 lower-case hex characters that identify the span of this service
+// - ParentSpanID: the span of the caller, empty when the trace started in this service
+// - Flags: the trace flags, see Sampled
+// - State: the vendor-specific tracestate, propagated as is
+type TraceContext struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Flags        byte
+	State        string
+}
+
+// NewTraceContext starts a sampled trace with random trace and span IDs.
+//
+// Returns:
+// - TraceContext: The new trace context.
+func NewTraceContext() TraceContext {
+	return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: 1}
+}
+
+// ParseTraceparent parses the value of a traceparent header.
+// Values of future versions are accepted as long as they start with the fields of version 00.
+//
+// Parameters:
+// - value: The value of the traceparent header, e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+//
+// Returns:
+// - TraceContext: The trace context of the caller, its span ID is the span of the caller.
+// - bool: Whether the value is a valid traceparent.
+func ParseTraceparent(value string) (TraceContext, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return TraceContext{}, false
+	}
+
+	version, traceID, spanID, flags := value[0:2], value[3:35], value[36:52], value[53:55]
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return TraceContext{}, false
+	}
+	if !isHex(version) || version == "ff" || (version == "00" && len(value) != 55) {
+		return TraceContext{}, false
+	}
+	if !isHex(traceID) || traceID == strings.Repeat("0", 32) || !isHex(spanID) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+	b, err := hex.DecodeString(flags)
+	if err != nil || !isHex(flags) {
+		return TraceContext{}, false
+	}
+
+	return TraceContext{TraceID: traceID, SpanID: spanID, Flags: b[0]}, true
+}
+
+// Child returns the trace context of a span started by the span of tc, with a random span ID.
+func (tc TraceContext) Child() TraceContext {
+	return TraceContext{TraceID: tc.TraceID, SpanID: randomHex(8), ParentSpanID: tc.SpanID, Flags: tc.Flags, State: tc.State}
+}
+
+// Sampled reports whether the caller may have recorded the trace.
+func (tc TraceContext) Sampled() bool {
+	return tc.Flags&1 == 1
+}
+
+// Traceparent returns the value of the traceparent header that makes the span of tc the parent of the callee.
+func (tc TraceContext) Traceparent() string {
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + hex.EncodeToString([]byte{tc.Flags})
+}
+
+// ContextWithTrace returns a copy of the context that carries the trace context.
+//
+// Parameters:
+// - ctx: The parent context.
+// - tc: The trace context of the request.
+//
+// Returns:
+// - context.Context: The context that carries the trace context.
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, Trace, tc)
+}
+
+// TraceFromContext returns the trace context carried by the context.
+//
+// Parameters:
+// - ctx: The context to read the trace context from.
+//
+// Returns:
+// - TraceContext: The trace context of the request.
+// - bool: Whether the context carries a trace context.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(Trace).(TraceContext)
+	return tc, ok
+}
+
+// InjectTrace sets the traceparent and tracestate headers of an outgoing request from the trace context
+// carried by the context, so the callee continues the trace. Nothing is set when the context has no trace context.
+//
+// Parameters:
+// - ctx: The context of the incoming request, or of the work that makes the call.
+// - header: The headers of the outgoing request.
+func InjectTrace(ctx context.Context, header http.Header) {
+	tc, ok := TraceFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	header.Set(Traceparent, tc.Traceparent())
+	if tc.State != "" {
+		header.Set(Tracestate, tc.State)
+	} else {
+		header.Del(Tracestate)
+	}
+}
+
+// TraceTransport is an http.RoundTripper that propagates the trace context of the request context
+// to outgoing requests, see InjectTrace. Requests that already have a traceparent header are sent as is.
+// It contains the following fields:
+// - Base: the http.RoundTripper that sends the requests, http.DefaultTransport when nil
+type TraceTransport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip sends the request with the trace context headers.
+func (t TraceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if _, ok := TraceFromContext(r.Context()); ok && r.Header.Get(Traceparent) == "" {
+		r = r.Clone(r.Context())
+		InjectTrace(r.Context(), r.Header)
+	}
+	return base.RoundTrip(r)
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		rand.Read(b) //nolint:errcheck
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AddVary adds header names to the Vary header of a response, skipping the names it already contains.
+//
+// Parameters:
+// - header: The headers of the response.
+// - names: The request header names the response varies on.
+func AddVary(header http.Header, names ...string) {
+	present := map[string]bool{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			present[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+
+	for _, name := range names {
+		if key := http.CanonicalHeaderKey(name); !present[key] && !present["*"] {
+			header.Add("Vary", key)
+			present[key] = true
+		}
+	}
+}